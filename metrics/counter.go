@@ -1,6 +1,12 @@
 package metrics
 
-import "sync/atomic"
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // Counter holds an int64 value that can be incremented and decremented.
 type Counter interface {
@@ -19,7 +25,7 @@ func GetOrRegisterCounter(name string, r Registry) Counter {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, NewCounter).(Counter)
+	return mustGetOrRegisterAs(r, name, NewCounter)
 }
 
 // NewCounter constructs a new StandardCounter.
@@ -27,7 +33,41 @@ func NewCounter() Counter {
 	if UseNilMetrics {
 		return NilCounter{}
 	}
-	return &StandardCounter{0}
+	return &StandardCounter{created: time.Now()}
+}
+
+// GetOrRegisterStripedCounter returns an existing Counter or constructs and
+// registers a new StripedCounter.
+func GetOrRegisterStripedCounter(name string, r Registry) Counter {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return mustGetOrRegisterAs(r, name, NewStripedCounter)
+}
+
+// NewStripedCounter constructs a new StripedCounter with enough stripes for
+// the current GOMAXPROCS.
+func NewStripedCounter() Counter {
+	if UseNilMetrics {
+		return NilCounter{}
+	}
+	// a power of two, so that a stripe is picked by the low bits of an id
+	n := bits.Len(uint(2*runtime.GOMAXPROCS(0) - 1))
+	return &StripedCounter{
+		stripes: make([]counterStripe, 1<<n),
+		mask:    1<<n - 1,
+		created: time.Now(),
+	}
+}
+
+// NewRegisteredStripedCounter constructs and registers a new StripedCounter.
+func NewRegisteredStripedCounter(name string, r Registry) Counter {
+	c := NewStripedCounter()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
 }
 
 // NewRegisteredCounter constructs and registers a new StandardCounter.
@@ -85,7 +125,8 @@ func (NilCounter) Snapshot() Counter { return NilCounter{} }
 // StandardCounter is the standard implementation of a Counter and uses the
 // sync/atomic package to manage a single int64 value.
 type StandardCounter struct {
-	count int64
+	count   int64
+	created time.Time
 }
 
 // Clear sets the counter to zero.
@@ -98,6 +139,11 @@ func (c *StandardCounter) Count() int64 {
 	return atomic.LoadInt64(&c.count)
 }
 
+// Created returns the time the counter was constructed.
+func (c *StandardCounter) Created() time.Time {
+	return c.created
+}
+
 // Dec decrements the counter by the given amount.
 func (c *StandardCounter) Dec(i int64) {
 	atomic.AddInt64(&c.count, -i)
@@ -112,3 +158,105 @@ func (c *StandardCounter) Inc(i int64) {
 func (c *StandardCounter) Snapshot() Counter {
 	return CounterSnapshot(c.Count())
 }
+
+// SnapshotAndReset returns a read-only copy of the counter and sets it to
+// zero in a single atomic operation, so that no increment is lost.
+func (c *StandardCounter) SnapshotAndReset() Counter {
+	return CounterSnapshot(c.Swap(0))
+}
+
+// Swap sets the counter to the given value and returns the previous one in a
+// single atomic operation.
+func (c *StandardCounter) Swap(i int64) int64 {
+	return atomic.SwapInt64(&c.count, i)
+}
+
+// cacheLineSize is the size of the cache lines that stripes are padded to.
+const cacheLineSize = 64
+
+// counterStripe is a part of a StripedCounter.  Its count is padded by a
+// cache line on both sides, so that no two counts share a cache line, nor a
+// count and another object, however the stripes happen to be aligned.
+type counterStripe struct {
+	_     [cacheLineSize]byte
+	count int64
+	_     [cacheLineSize - 8]byte
+}
+
+// stripeIDs hands out stripe ids.  A sync.Pool keeps its items per P, the
+// runtime's processor running goroutines, so that goroutines running on the
+// same P mostly get the same id and goroutines running at the same time on
+// different Ps get different ones.
+var stripeIDs = sync.Pool{
+	New: func() interface{} {
+		id := atomic.AddUint32(&nextStripeID, 1)
+		return &id
+	},
+}
+
+var nextStripeID uint32
+
+// StripedCounter is an implementation of a Counter for counters updated from
+// many goroutines at once.  Every goroutine updates the stripe of the P it
+// runs on, each on its own cache line, so that concurrent updates rarely
+// contend for one.  Reads sum all stripes and are therefore slower than with a
+// StandardCounter.
+type StripedCounter struct {
+	stripes []counterStripe
+	mask    uint32
+	created time.Time
+}
+
+// Clear sets the counter to zero.
+func (c *StripedCounter) Clear() {
+	for i := range c.stripes {
+		atomic.StoreInt64(&c.stripes[i].count, 0)
+	}
+}
+
+// Count returns the current count.
+func (c *StripedCounter) Count() int64 {
+	var count int64
+	for i := range c.stripes {
+		count += atomic.LoadInt64(&c.stripes[i].count)
+	}
+	return count
+}
+
+// Created returns the time the counter was constructed.
+func (c *StripedCounter) Created() time.Time {
+	return c.created
+}
+
+// Dec decrements the counter by the given amount.
+func (c *StripedCounter) Dec(i int64) {
+	atomic.AddInt64(&c.stripe().count, -i)
+}
+
+// Inc increments the counter by the given amount.
+func (c *StripedCounter) Inc(i int64) {
+	atomic.AddInt64(&c.stripe().count, i)
+}
+
+// Snapshot returns a read-only copy of the counter.
+func (c *StripedCounter) Snapshot() Counter {
+	return CounterSnapshot(c.Count())
+}
+
+// SnapshotAndReset returns a read-only copy of the counter and sets it to
+// zero.  Every stripe is swapped atomically, so that no increment is lost.
+func (c *StripedCounter) SnapshotAndReset() Counter {
+	var count int64
+	for i := range c.stripes {
+		count += atomic.SwapInt64(&c.stripes[i].count, 0)
+	}
+	return CounterSnapshot(count)
+}
+
+// stripe returns the stripe of the P running the calling goroutine.
+func (c *StripedCounter) stripe() *counterStripe {
+	id := stripeIDs.Get().(*uint32)
+	stripe := &c.stripes[*id&c.mask]
+	stripeIDs.Put(id)
+	return stripe
+}
@@ -9,3 +9,59 @@ func BenchmarkCounter(b *testing.B) {
 		c.Inc(1)
 	}
 }
+
+func BenchmarkCounterParallel(b *testing.B) {
+	c := NewCounter()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc(1)
+		}
+	})
+}
+
+func BenchmarkStripedCounter(b *testing.B) {
+	c := NewStripedCounter()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc(1)
+	}
+}
+
+func BenchmarkStripedCounterParallel(b *testing.B) {
+	c := NewStripedCounter()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc(1)
+		}
+	})
+}
+
+// The contended benchmarks show the gain of striping, which grows with the
+// number of CPUs: go test -bench Contended -cpu 1,4,16
+func BenchmarkCounterContended(b *testing.B) {
+	benchmarkCounterContended(b, NewCounter())
+}
+
+func BenchmarkStripedCounterContended(b *testing.B) {
+	benchmarkCounterContended(b, NewStripedCounter())
+}
+
+func benchmarkCounterContended(b *testing.B, c Counter) {
+	b.SetParallelism(4)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc(1)
+		}
+	})
+}
+
+func BenchmarkStripedCounterCount(b *testing.B) {
+	c := NewStripedCounter()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Count()
+	}
+}
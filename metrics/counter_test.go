@@ -1,12 +1,16 @@
 package metrics
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 // Check the interfaces are satisfied
 func TestCounter_impl(t *testing.T) {
 	var _ Counter = new(NilCounter)
 	var _ Counter = new(CounterSnapshot)
 	var _ Counter = new(StandardCounter)
+	var _ Counter = new(StripedCounter)
 }
 
 func TestCounterClear(t *testing.T) {
@@ -74,3 +78,74 @@ func TestGetOrRegisterCounter(t *testing.T) {
 		t.Fatal(c)
 	}
 }
+
+func TestCounterSnapshotAndReset(t *testing.T) {
+	c := NewCounter().(*StandardCounter)
+	c.Inc(47)
+	snapshot := c.SnapshotAndReset()
+	c.Inc(1)
+	if count := snapshot.Count(); 47 != count {
+		t.Errorf("snapshot.Count(): 47 != %v\n", count)
+	}
+	if count := c.Count(); 1 != count {
+		t.Errorf("c.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestCounterSwap(t *testing.T) {
+	c := NewCounter().(*StandardCounter)
+	c.Inc(47)
+	if prev := c.Swap(5); 47 != prev {
+		t.Errorf("c.Swap(5): 47 != %v\n", prev)
+	}
+	if count := c.Count(); 5 != count {
+		t.Errorf("c.Count(): 5 != %v\n", count)
+	}
+}
+
+func TestStripedCounter(t *testing.T) {
+	c := NewStripedCounter()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc(2)
+				c.Dec(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if count := c.Count(); 8000 != count {
+		t.Errorf("c.Count(): 8000 != %v\n", count)
+	}
+	if count := c.Snapshot().Count(); 8000 != count {
+		t.Errorf("c.Snapshot().Count(): 8000 != %v\n", count)
+	}
+	c.Clear()
+	if count := c.Count(); 0 != count {
+		t.Errorf("c.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestStripedCounterSnapshotAndReset(t *testing.T) {
+	c := NewStripedCounter().(*StripedCounter)
+	c.Inc(47)
+	snapshot := c.SnapshotAndReset()
+	c.Inc(1)
+	if count := snapshot.Count(); 47 != count {
+		t.Errorf("snapshot.Count(): 47 != %v\n", count)
+	}
+	if count := c.Count(); 1 != count {
+		t.Errorf("c.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestGetOrRegisterStripedCounter(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredStripedCounter("foo", r).Inc(47)
+	if c := GetOrRegisterStripedCounter("foo", r); 47 != c.Count() {
+		t.Fatal(c)
+	}
+}
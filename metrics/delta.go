@@ -0,0 +1,281 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DeltaSnapshotter takes snapshots of the changes of a cumulative registry
+// since the previous snapshot, for reporters of push-based backends which
+// expect per-interval values.
+//
+// Counters, the counts of Meters and the counts and sums of Histograms and
+// Timers hold the change since the previous snapshot, as do the buckets of
+// Histograms with a BucketSample.  Deltas are computed from the values of the
+// previous snapshot, so the registry is not modified and other exporters of
+// the same registry are not affected.  The other statistics of Histograms and
+// Timers cannot be told for the interval this way: the minimum and maximum of
+// a BucketSample are bounded by the buckets holding the values of the
+// interval, and those of other samples, like their percentiles, are zero.
+// Use NewResettingDeltaSnapshotter for registries owned by the reporter to
+// get all of them.  The members of MultiMetrics and the children of
+// MetricVecs are handled the same way.  All other metrics, including
+// WatermarkGauges, are copied as they are.
+type DeltaSnapshotter struct {
+	registry Registry
+	reset    bool
+	last     map[string]deltaState
+	mutex    sync.Mutex
+}
+
+// deltaState is what a DeltaSnapshotter remembers of a metric between two
+// snapshots.
+type deltaState struct {
+	count, sum int64
+	counts     []int64 // per bucket, for BucketSamples
+	m2         float64 // for BucketSamples
+}
+
+// NewDeltaSnapshotter constructs a new DeltaSnapshotter for the given
+// registry, or for the DefaultRegistry if r is nil.
+func NewDeltaSnapshotter(r Registry) *DeltaSnapshotter {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return &DeltaSnapshotter{
+		registry: r,
+		last:     make(map[string]deltaState),
+	}
+}
+
+// NewResettingDeltaSnapshotter constructs a new DeltaSnapshotter for the given
+// registry, or for the DefaultRegistry if r is nil, which clears Histograms
+// and the histograms of Timers with SnapshotAndReset on every snapshot, so
+// that all of their statistics cover the interval.  Other exporters of the
+// registry only see the values since the previous snapshot, so it is meant for
+// registries owned by a single reporter.
+func NewResettingDeltaSnapshotter(r Registry) *DeltaSnapshotter {
+	d := NewDeltaSnapshotter(r)
+	d.reset = true
+	return d
+}
+
+// Snapshot takes a snapshot of the changes since the previous snapshot, or
+// since the metrics were created for the first one.
+func (d *DeltaSnapshotter) Snapshot() *RegistrySnapshot {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var s *RegistrySnapshot
+	if d.reset {
+		s = &RegistrySnapshot{time: time.Now()}
+		s.entries = sortedRegistryEntries(d.registry)
+		for i, e := range s.entries {
+			if cm, ok := e.metric.(createdMetric); ok {
+				s.entries[i].created = cm.Created()
+			}
+			s.entries[i].metric = snapshotAndResetHistograms(e.metric)
+		}
+	} else {
+		s = SnapshotRegistry(d.registry)
+	}
+	last := make(map[string]deltaState, len(s.entries))
+	for i, e := range s.entries {
+		s.entries[i].metric = d.delta(formatTaggedName(e.name, e.tags), e.metric, last)
+	}
+	d.last = last
+	return s
+}
+
+// nestedSnapshotter is implemented by metrics which contain other metrics and
+// can copy them with a given function.
+type nestedSnapshotter interface {
+	snapshotWith(func(Metric) Metric) Metric
+}
+
+// snapshotAndResetHistograms is SnapshotMetric, except that Histograms and
+// the histograms of Timers are cleared, also within MultiMetrics and
+// MetricVecs.
+func snapshotAndResetHistograms(m Metric) Metric {
+	switch metric := m.(type) {
+	case Histogram:
+		return snapshotAndResetHistogram(metric)
+	case interface{ SnapshotAndReset() Timer }:
+		return metric.SnapshotAndReset()
+	case nestedSnapshotter:
+		return metric.snapshotWith(snapshotAndResetHistograms)
+	}
+	return SnapshotMetric(m)
+}
+
+// delta returns the change of a snapshotted metric since the previous
+// snapshot and records its current state in last under the given key.
+func (d *DeltaSnapshotter) delta(key string, m Metric, last map[string]deltaState) Metric {
+	switch metric := m.(type) {
+	case Counter:
+		count := metric.Count()
+		last[key] = deltaState{count: count}
+		return CounterSnapshot(count - d.last[key].count)
+	case Histogram:
+		if d.reset {
+			return metric
+		}
+		return &HistogramSnapshot{sample: d.sample(key, metric.Sample(), last)}
+	case Meter:
+		count := metric.Count()
+		last[key] = deltaState{count: count}
+		return &MeterSnapshot{
+			count:    count - d.last[key].count,
+			rate1:    metric.Rate1(),
+			rate5:    metric.Rate5(),
+			rate15:   metric.Rate15(),
+			rateMean: metric.RateMean(),
+		}
+	case *TimerSnapshot:
+		return &TimerSnapshot{
+			histogram: d.delta(key+"\xffhistogram", metric.histogram, last).(Histogram),
+			meter:     d.delta(key+"\xffmeter", metric.meter, last).(Meter),
+		}
+	case MultiMetric:
+		members := make(map[string]Metric, metric.Len())
+		metric.Each(func(k string, m Metric) {
+			members[k] = d.delta(key+"\xff"+k, m, last)
+		})
+		return &MultiMetricSnapshot{&StandardMultiMetric{metrics: members, tags: metric.Tags()}}
+	case *MetricVecSnapshot:
+		children := make([]vecSnapshotChild, len(metric.children))
+		for i, c := range metric.children {
+			children[i] = c
+			children[i].metric = d.delta(key+"\xff"+formatTags(c.labels), c.metric, last)
+		}
+		return &MetricVecSnapshot{labelNames: metric.labelNames, children: children}
+	}
+	return m
+}
+
+// sample returns the change of a snapshotted sample since the previous
+// snapshot.  A sample which holds fewer values than on the previous snapshot
+// was cleared in between, so all of its values are new.
+func (d *DeltaSnapshotter) sample(key string, s Sample, last map[string]deltaState) Sample {
+	prev := d.last[key]
+	if s.Count() < prev.count {
+		prev = deltaState{}
+	}
+	if b, ok := s.(*BucketSampleSnapshot); ok {
+		last[key] = deltaState{count: b.count, sum: b.sum, counts: b.counts, m2: b.m2}
+		if len(prev.counts) != len(b.counts) {
+			prev = deltaState{counts: make([]int64, len(b.counts))}
+		}
+		return bucketDelta(b, prev)
+	}
+	last[key] = deltaState{count: s.Count(), sum: s.Sum()}
+	return &deltaSample{count: s.Count() - prev.count, sum: s.Sum() - prev.sum}
+}
+
+// bucketDelta returns the change of a BucketSampleSnapshot since the given
+// state.  The minimum and maximum are bounded by the buckets holding the
+// values of the interval.
+func bucketDelta(b *BucketSampleSnapshot, prev deltaState) *BucketSampleSnapshot {
+	delta := &BucketSampleSnapshot{bounds: b.bounds}
+	delta.counts = make([]int64, len(b.counts))
+	lowest, highest := -1, -1
+	for i := range b.counts {
+		delta.counts[i] = b.counts[i] - prev.counts[i]
+		if 0 != delta.counts[i] {
+			if -1 == lowest {
+				lowest = i
+			}
+			highest = i
+		}
+	}
+	delta.count = b.count - prev.count
+	delta.sum = b.sum - prev.sum
+	if 0 == delta.count {
+		return delta
+	}
+	delta.min, delta.max = b.min, b.max
+	// a bucket holds the values above the previous bound up to its own
+	if lowest > 0 && inInt64Range(b.bounds[lowest-1]) {
+		if bound := int64(math.Floor(b.bounds[lowest-1])) + 1; bound > delta.min {
+			delta.min = bound
+		}
+	}
+	if highest < len(b.bounds) && inInt64Range(b.bounds[highest]) {
+		if bound := int64(math.Floor(b.bounds[highest])); bound < delta.max {
+			delta.max = bound
+		}
+	}
+	if prev.count > 0 {
+		// Chan et al.: m2 = m2a + m2b + d²·na·nb/n, solved for m2b.
+		d := delta.mean() - float64(prev.sum)/float64(prev.count)
+		n := float64(b.count)
+		delta.m2 = math.Max(0, b.m2-prev.m2-d*d*float64(prev.count)*float64(delta.count)/n)
+	} else {
+		delta.m2 = b.m2
+	}
+	return delta
+}
+
+func inInt64Range(v float64) bool {
+	return v > float64(math.MinInt64) && v < float64(math.MaxInt64)
+}
+
+// deltaSample is a read-only Sample holding the count and sum of the values
+// recorded in the interval since the previous snapshot.  Its other statistics
+// cannot be told from cumulative samples and are zero.
+type deltaSample struct {
+	count, sum int64
+}
+
+// Clear panics.
+func (*deltaSample) Clear() {
+	panic("Clear called on a deltaSample")
+}
+
+// Count returns the number of values recorded in the interval.
+func (s *deltaSample) Count() int64 { return s.count }
+
+// Max returns zero.
+func (*deltaSample) Max() int64 { return 0 }
+
+// Mean returns the mean of the values recorded in the interval.
+func (s *deltaSample) Mean() float64 {
+	if 0 == s.count {
+		return 0.0
+	}
+	return float64(s.sum) / float64(s.count)
+}
+
+// Min returns zero.
+func (*deltaSample) Min() int64 { return 0 }
+
+// Percentile returns zero.
+func (*deltaSample) Percentile(float64) float64 { return 0.0 }
+
+// Percentiles returns a slice of zeros.
+func (*deltaSample) Percentiles(ps []float64) []float64 {
+	return make([]float64, len(ps))
+}
+
+// Size returns zero.
+func (*deltaSample) Size() int { return 0 }
+
+// Snapshot returns the sample.
+func (s *deltaSample) Snapshot() Sample { return s }
+
+// StdDev returns zero.
+func (*deltaSample) StdDev() float64 { return 0.0 }
+
+// Sum returns the sum of the values recorded in the interval.
+func (s *deltaSample) Sum() int64 { return s.sum }
+
+// Update panics.
+func (*deltaSample) Update(int64) {
+	panic("Update called on a deltaSample")
+}
+
+// Values returns an empty slice.
+func (*deltaSample) Values() []int64 { return []int64{} }
+
+// Variance returns zero.
+func (*deltaSample) Variance() float64 { return 0.0 }
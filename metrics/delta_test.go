@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDeltaSnapshotter(t *testing.T) {
+	r := NewRegistry()
+	c := GetOrRegisterCounter("counter", r)
+	h := GetOrRegisterHistogram("histogram", r, NewUniformSample(100))
+	g := GetOrRegisterGauge("gauge", r)
+	d := NewDeltaSnapshotter(r)
+
+	c.Inc(5)
+	h.Update(1)
+	h.Update(2)
+	g.Update(47)
+	s := d.Snapshot()
+	if count := s.Counter("counter").Count(); 5 != count {
+		t.Errorf("s.Counter(\"counter\").Count(): 5 != %v\n", count)
+	}
+	if count := s.Histogram("histogram").Count(); 2 != count {
+		t.Errorf("s.Histogram(\"histogram\").Count(): 2 != %v\n", count)
+	}
+
+	c.Inc(3)
+	h.Update(10)
+	s = d.Snapshot()
+	if count := s.Counter("counter").Count(); 3 != count {
+		t.Errorf("s.Counter(\"counter\").Count(): 3 != %v\n", count)
+	}
+	if count := c.Count(); 8 != count {
+		t.Errorf("c.Count(): 8 != %v\n", count)
+	}
+	if sum := s.Histogram("histogram").Sum(); 10 != sum {
+		t.Errorf("s.Histogram(\"histogram\").Sum(): 10 != %v\n", sum)
+	}
+	if count := h.Count(); 3 != count {
+		t.Errorf("h.Count(): 3 != %v\n", count)
+	}
+	if value := s.Gauge("gauge").Value(); 47 != value {
+		t.Errorf("s.Gauge(\"gauge\").Value(): 47 != %v\n", value)
+	}
+	// statistics which cannot be told for the interval are zero
+	if max, p99 := s.Histogram("histogram").Max(), s.Histogram("histogram").Percentile(0.99); 0 != max || 0 != p99 {
+		t.Errorf("s.Histogram(\"histogram\").Max(), Percentile(0.99): 0, 0 != %v, %v\n", max, p99)
+	}
+}
+
+func TestResettingDeltaSnapshotter(t *testing.T) {
+	r := NewRegistry()
+	c := GetOrRegisterCounter("counter", r)
+	h := GetOrRegisterHistogram("histogram", r, NewUniformSample(100))
+	tm := NewRegisteredTimer("timer", r)
+	defer tm.Stop()
+	v := NewRegisteredHistogramVec("latency", r, func() Sample { return NewUniformSample(100) }, "code")
+	d := NewResettingDeltaSnapshotter(r)
+
+	c.Inc(5)
+	h.Update(100)
+	tm.Update(time.Second)
+	v.WithLabelValues("200").Update(100)
+	d.Snapshot()
+	c.Inc(3)
+	h.Update(1)
+	h.Update(2)
+	tm.Update(time.Millisecond)
+	v.WithLabelValues("200").Update(1)
+	s := d.Snapshot()
+	if count := s.Counter("counter").Count(); 3 != count {
+		t.Errorf("counter.Count(): 3 != %v\n", count)
+	}
+	if count, max := s.Histogram("histogram").Count(), s.Histogram("histogram").Max(); 2 != count || 2 != max {
+		t.Errorf("histogram.Count(), Max(): 2, 2 != %v, %v\n", count, max)
+	}
+	if count, max := s.Timer("timer").Count(), s.Timer("timer").Max(); 1 != count || int64(time.Millisecond) != max {
+		t.Errorf("timer.Count(), Max(): 1, %v != %v, %v\n", int64(time.Millisecond), count, max)
+	}
+	s.Get("latency").(MetricVec).Each(func(labels map[string]string, m Metric) {
+		if max := m.(Histogram).Max(); 1 != max {
+			t.Errorf("latency.Max(): 1 != %v\n", max)
+		}
+	})
+	if count := h.Count(); 0 != count {
+		t.Errorf("h.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestDeltaSnapshotterMeter(t *testing.T) {
+	r := NewRegistry()
+	m := GetOrRegisterMeter("meter", r)
+	defer m.Stop()
+	d := NewDeltaSnapshotter(r)
+
+	m.Mark(47)
+	d.Snapshot()
+	m.Mark(3)
+	if count := d.Snapshot().Meter("meter").Count(); 3 != count {
+		t.Errorf("meter.Count(): 3 != %v\n", count)
+	}
+}
+
+func TestDeltaSnapshotterNested(t *testing.T) {
+	r := NewRegistry()
+	mm := NewRegisteredMultiMetric("http", nil, r)
+	hits := mm.GetOrAdd("hits", NewCounter()).(Counter)
+	v := NewRegisteredCounterVec("requests", r, "code")
+	tm := NewRegisteredTimer("timer", r)
+	defer tm.Stop()
+	d := NewDeltaSnapshotter(r)
+
+	hits.Inc(3)
+	v.WithLabelValues("200").Inc(3)
+	tm.Update(time.Second)
+	tm.Update(time.Second)
+	d.Snapshot()
+	hits.Inc(1)
+	v.WithLabelValues("200").Inc(1)
+	tm.Update(time.Second)
+	s := d.Snapshot()
+	if count := s.MultiMetric("http").Metrics()["hits"].(Counter).Count(); 1 != count {
+		t.Errorf("http.hits: 1 != %v\n", count)
+	}
+	s.Get("requests").(MetricVec).Each(func(labels map[string]string, m Metric) {
+		if count := m.(Counter).Count(); 1 != count {
+			t.Errorf("requests: 1 != %v\n", count)
+		}
+	})
+	if count := s.Timer("timer").Count(); 1 != count {
+		t.Errorf("timer.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestDeltaSnapshotterBucketHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredBucketHistogram("latency", r, []float64{1, 10})
+	d := NewDeltaSnapshotter(r)
+
+	h.Update(1)
+	h.Update(5)
+	d.Snapshot()
+	h.Update(5)
+	h.Update(20)
+	s := d.Snapshot()
+	_, counts := s.Histogram("latency").Sample().(BucketSample).Buckets()
+	if 0 != counts[0] || 1 != counts[1] {
+		t.Errorf("buckets: [0 1] != %v\n", counts)
+	}
+	if count := s.Histogram("latency").Count(); 2 != count {
+		t.Errorf("latency.Count(): 2 != %v\n", count)
+	}
+	if min, max := s.Histogram("latency").Min(), s.Histogram("latency").Max(); 2 != min || 20 != max {
+		t.Errorf("latency.Min(), Max(): 2, 20 != %v, %v\n", min, max)
+	}
+	if variance := s.Histogram("latency").Variance(); math.Abs(56.25-variance) > 1e-9 {
+		t.Errorf("latency.Variance(): 56.25 != %v\n", variance)
+	}
+
+	// other exporters of the registry still see cumulative values
+	if count := SnapshotRegistry(r).Histogram("latency").Count(); 4 != count {
+		t.Errorf("SnapshotRegistry(r) latency.Count(): 4 != %v\n", count)
+	}
+}
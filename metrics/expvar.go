@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"strings"
+)
+
+// ExpvarRegistry is an expvar.Var exposing the metrics of a Registry, in the
+// same way an expvar.Map exposes its members.  The registry is enumerated and
+// every metric is read at the time the variable is read, so metrics
+// registered after publishing are included and values are always current.
+type ExpvarRegistry struct {
+	registry Registry
+}
+
+// NewExpvarRegistry constructs a new ExpvarRegistry for the given registry
+// without publishing it.
+func NewExpvarRegistry(r Registry) *ExpvarRegistry {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return &ExpvarRegistry{registry: r}
+}
+
+// PublishExpvar publishes the metrics of the given registry as an expvar
+// variable with the given name.  Like expvar.Publish, it panics if the name is
+// already in use.
+func PublishExpvar(name string, r Registry) *ExpvarRegistry {
+	v := NewExpvarRegistry(r)
+	expvar.Publish(name, v)
+	return v
+}
+
+// Do calls f for each metric in the registry, in lexicographical order of
+// names.  Tagged metrics are keyed by "name{k1=v1,k2=v2}".
+func (v *ExpvarRegistry) Do(f func(expvar.KeyValue)) {
+	for _, e := range SnapshotRegistry(v.registry).entries {
+		if value := expvarMetric(e.metric); nil != value {
+			f(expvar.KeyValue{Key: formatTaggedName(e.name, e.tags), Value: value})
+		}
+	}
+}
+
+// Get returns the expvar.Var of the metric with the given name and without
+// tags or nil if none is registered.
+func (v *ExpvarRegistry) Get(name string) expvar.Var {
+	m := v.registry.Get(name)
+	if nil == m {
+		return nil
+	}
+	return expvarMetric(m)
+}
+
+// GetTagged returns the expvar.Var of the metric with the given name and tags
+// or nil if none is registered.
+func (v *ExpvarRegistry) GetTagged(name string, tags map[string]string) expvar.Var {
+	if 0 == len(tags) {
+		return v.Get(name)
+	}
+	r, ok := v.registry.(TaggedRegistry)
+	if !ok {
+		return nil
+	}
+	m := r.GetTagged(name, tags)
+	if nil == m {
+		return nil
+	}
+	return expvarMetric(m)
+}
+
+// String returns the JSON representation of the metrics in the registry.
+func (v *ExpvarRegistry) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	first := true
+	v.Do(func(kv expvar.KeyValue) {
+		if !first {
+			b.WriteString(", ")
+		}
+		first = false
+		b.WriteString(strconv.Quote(kv.Key))
+		b.WriteString(": ")
+		b.WriteString(kv.Value.String())
+	})
+	b.WriteByte('}')
+	return b.String()
+}
+
+// expvarFunc is an expvar.Var whose value is computed by a function at read
+// time.  Unlike expvar.Func it yields valid JSON when the value cannot be
+// marshalled.
+type expvarFunc func() interface{}
+
+func (f expvarFunc) String() string {
+	b, err := json.Marshal(f())
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+// expvarMetric returns an expvar.Var reading the given metric, or nil if the
+// metric is of a type metricJSON does not know.
+func expvarMetric(m Metric) expvar.Var {
+	switch m.(type) {
+	case Counter, Gauge, GaugeFloat64, Histogram, Meter, Timer, MultiMetric, MetricVec:
+		return expvarFunc(func() interface{} {
+			return metricJSON(m)
+		})
+	}
+	return nil
+}
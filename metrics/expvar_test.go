@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+)
+
+// Check the interfaces are satisfied
+func TestExpvarRegistry_impl(t *testing.T) {
+	var _ expvar.Var = new(ExpvarRegistry)
+}
+
+// publishExpvarRuns makes the published name unique when the test is run
+// more than once in a process, as with -count, since expvar names cannot be
+// reused.
+var publishExpvarRuns int
+
+func TestPublishExpvar(t *testing.T) {
+	publishExpvarRuns++
+	name := fmt.Sprintf("TestPublishExpvar%d", publishExpvarRuns)
+	r := NewRegistry()
+	c := NewRegisteredCounter("counter", r)
+	PublishExpvar(name, r)
+
+	// registered after publishing
+	NewRegisteredGauge("gauge", r).Update(47)
+	c.Inc(1)
+
+	var data map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &data); nil != err {
+		t.Fatal(err)
+	}
+	if v := data["counter"]["count"]; 1.0 != v {
+		t.Errorf("counter.count: 1 != %v\n", v)
+	}
+	if v := data["gauge"]["value"]; 47.0 != v {
+		t.Errorf("gauge.value: 47 != %v\n", v)
+	}
+}
+
+func TestExpvarRegistryGet(t *testing.T) {
+	r := NewRegistry()
+	v := NewExpvarRegistry(r)
+	if nil != v.Get("foo") {
+		t.Fatal("v.Get(\"foo\") != nil")
+	}
+	c := NewRegisteredCounter("foo", r)
+	foo := v.Get("foo")
+	c.Inc(2)
+	if s := foo.String(); "{\"count\":2}" != s {
+		t.Errorf("foo.String(): %s\n", s)
+	}
+}
+
+func TestExpvarRegistryGetTagged(t *testing.T) {
+	r := NewRegistry().(TaggedRegistry)
+	r.RegisterTagged("hits", map[string]string{"host": "a"}, NewCounter())
+	c := NewCounter()
+	c.Inc(2)
+	r.RegisterTagged("hits", map[string]string{"host": "b"}, c)
+	v := NewExpvarRegistry(r)
+	if nil != v.Get("hits") {
+		t.Fatal("v.Get(\"hits\") != nil")
+	}
+	if s := v.GetTagged("hits", map[string]string{"host": "b"}).String(); "{\"count\":2}" != s {
+		t.Errorf("v.GetTagged(\"hits\", host=b).String(): %s\n", s)
+	}
+	if nil != v.GetTagged("hits", map[string]string{"host": "c"}) {
+		t.Fatal("v.GetTagged(\"hits\", host=c) != nil")
+	}
+}
+
+func TestExpvarRegistryNaN(t *testing.T) {
+	r := NewRegistry()
+	g := NewRegisteredGaugeFloat64("nan", r)
+	g.Update(0)
+	g.Update(g.Value() / g.Value())
+	v := NewExpvarRegistry(r)
+	if s := v.String(); "{\"nan\": {\"value\":\"NaN\"}}" != s {
+		t.Errorf("v.String(): %s\n", s)
+	}
+}
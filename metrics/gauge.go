@@ -6,6 +6,9 @@ import "sync/atomic"
 type Gauge interface {
 	Metric
 
+	Add(int64)
+	Dec()
+	Inc()
 	Snapshot() Gauge
 	Update(int64)
 	Value() int64
@@ -17,7 +20,16 @@ func GetOrRegisterGauge(name string, r Registry) Gauge {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, NewGauge).(Gauge)
+	return mustGetOrRegisterAs(r, name, NewGauge)
+}
+
+// GetOrRegisterFunctionalGauge returns an existing Gauge or constructs and
+// registers a new FunctionalGauge.
+func GetOrRegisterFunctionalGauge(name string, r Registry, f func() int64) Gauge {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return mustGetOrRegisterAs(r, name, func() Gauge { return NewFunctionalGauge(f) })
 }
 
 // NewGauge constructs a new StandardGauge.
@@ -38,9 +50,43 @@ func NewRegisteredGauge(name string, r Registry) Gauge {
 	return c
 }
 
+// NewFunctionalGauge constructs a new FunctionalGauge.
+func NewFunctionalGauge(f func() int64) Gauge {
+	if UseNilMetrics {
+		return NilGauge{}
+	}
+	return &FunctionalGauge{value: f}
+}
+
+// NewRegisteredFunctionalGauge constructs and registers a new
+// FunctionalGauge.
+func NewRegisteredFunctionalGauge(name string, r Registry, f func() int64) Gauge {
+	c := NewFunctionalGauge(f)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
 // GaugeSnapshot is a read-only copy of another Gauge.
 type GaugeSnapshot int64
 
+// Add panics.
+func (GaugeSnapshot) Add(int64) {
+	panic("Add called on a GaugeSnapshot")
+}
+
+// Dec panics.
+func (GaugeSnapshot) Dec() {
+	panic("Dec called on a GaugeSnapshot")
+}
+
+// Inc panics.
+func (GaugeSnapshot) Inc() {
+	panic("Inc called on a GaugeSnapshot")
+}
+
 // Snapshot returns the snapshot.
 func (g GaugeSnapshot) Snapshot() Gauge { return g }
 
@@ -55,6 +101,15 @@ func (g GaugeSnapshot) Value() int64 { return int64(g) }
 // NilGauge is a no-op Gauge.
 type NilGauge struct{}
 
+// Add is a no-op.
+func (NilGauge) Add(delta int64) {}
+
+// Dec is a no-op.
+func (NilGauge) Dec() {}
+
+// Inc is a no-op.
+func (NilGauge) Inc() {}
+
 // Snapshot is a no-op.
 func (NilGauge) Snapshot() Gauge { return NilGauge{} }
 
@@ -70,6 +125,21 @@ type StandardGauge struct {
 	value int64
 }
 
+// Add adds the given delta to the gauge's value.
+func (g *StandardGauge) Add(delta int64) {
+	atomic.AddInt64(&g.value, delta)
+}
+
+// Dec decrements the gauge's value by one.
+func (g *StandardGauge) Dec() {
+	g.Add(-1)
+}
+
+// Inc increments the gauge's value by one.
+func (g *StandardGauge) Inc() {
+	g.Add(1)
+}
+
 // Snapshot returns a read-only copy of the gauge.
 func (g *StandardGauge) Snapshot() Gauge {
 	return GaugeSnapshot(g.Value())
@@ -84,3 +154,39 @@ func (g *StandardGauge) Update(v int64) {
 func (g *StandardGauge) Value() int64 {
 	return atomic.LoadInt64(&g.value)
 }
+
+// FunctionalGauge returns the value of a function every time it is read, for
+// gauges mirroring a value kept elsewhere.
+type FunctionalGauge struct {
+	value func() int64
+}
+
+// Add panics.
+func (*FunctionalGauge) Add(int64) {
+	panic("Add called on a FunctionalGauge")
+}
+
+// Dec panics.
+func (*FunctionalGauge) Dec() {
+	panic("Dec called on a FunctionalGauge")
+}
+
+// Inc panics.
+func (*FunctionalGauge) Inc() {
+	panic("Inc called on a FunctionalGauge")
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *FunctionalGauge) Snapshot() Gauge {
+	return GaugeSnapshot(g.Value())
+}
+
+// Update panics.
+func (*FunctionalGauge) Update(int64) {
+	panic("Update called on a FunctionalGauge")
+}
+
+// Value returns the current value of the function.
+func (g *FunctionalGauge) Value() int64 {
+	return g.value()
+}
@@ -1,6 +1,9 @@
 package metrics
 
-import "sync"
+import (
+	"math"
+	"sync/atomic"
+)
 
 // GaugeFloat64 holds a float64 value that can be set arbitrarily.
 type GaugeFloat64 interface {
@@ -17,7 +20,16 @@ func GetOrRegisterGaugeFloat64(name string, r Registry) GaugeFloat64 {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, NewGaugeFloat64()).(GaugeFloat64)
+	return mustGetOrRegisterAs(r, name, NewGaugeFloat64)
+}
+
+// GetOrRegisterFunctionalGaugeFloat64 returns an existing GaugeFloat64 or
+// constructs and registers a new FunctionalGaugeFloat64.
+func GetOrRegisterFunctionalGaugeFloat64(name string, r Registry, f func() float64) GaugeFloat64 {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return mustGetOrRegisterAs(r, name, func() GaugeFloat64 { return NewFunctionalGaugeFloat64(f) })
 }
 
 // NewGaugeFloat64 constructs a new StandardGaugeFloat64.
@@ -25,9 +37,7 @@ func NewGaugeFloat64() GaugeFloat64 {
 	if UseNilMetrics {
 		return NilGaugeFloat64{}
 	}
-	return &StandardGaugeFloat64{
-		value: 0.0,
-	}
+	return &StandardGaugeFloat64{}
 }
 
 // NewRegisteredGaugeFloat64 constructs and registers a new StandardGaugeFloat64.
@@ -40,6 +50,25 @@ func NewRegisteredGaugeFloat64(name string, r Registry) GaugeFloat64 {
 	return c
 }
 
+// NewFunctionalGaugeFloat64 constructs a new FunctionalGaugeFloat64.
+func NewFunctionalGaugeFloat64(f func() float64) GaugeFloat64 {
+	if UseNilMetrics {
+		return NilGaugeFloat64{}
+	}
+	return &FunctionalGaugeFloat64{value: f}
+}
+
+// NewRegisteredFunctionalGaugeFloat64 constructs and registers a new
+// FunctionalGaugeFloat64.
+func NewRegisteredFunctionalGaugeFloat64(name string, r Registry, f func() float64) GaugeFloat64 {
+	c := NewFunctionalGaugeFloat64(f)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
 // GaugeFloat64Snapshot is a read-only copy of another GaugeFloat64.
 type GaugeFloat64Snapshot float64
 
@@ -67,10 +96,37 @@ func (NilGaugeFloat64) Update(v float64) {}
 func (NilGaugeFloat64) Value() float64 { return 0.0 }
 
 // StandardGaugeFloat64 is the standard implementation of a GaugeFloat64 and
-// uses sync.Mutex to manage a single float64 value.
+// uses the sync/atomic package to manage a single float64 value, stored as
+// its IEEE 754 bits.
 type StandardGaugeFloat64 struct {
-	mutex sync.Mutex
-	value float64
+	bits uint64
+}
+
+// Add adds the given delta to the gauge's value.
+func (g *StandardGaugeFloat64) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		v := math.Float64frombits(old) + delta
+		if atomic.CompareAndSwapUint64(&g.bits, old, math.Float64bits(v)) {
+			return
+		}
+	}
+}
+
+// CompareAndSwap sets the gauge's value to new if it is old and reports
+// whether it did.  Values are compared by their bits, so NaN matches NaN.
+func (g *StandardGaugeFloat64) CompareAndSwap(old, new float64) bool {
+	return atomic.CompareAndSwapUint64(&g.bits, math.Float64bits(old), math.Float64bits(new))
+}
+
+// Dec decrements the gauge's value by one.
+func (g *StandardGaugeFloat64) Dec() {
+	g.Add(-1)
+}
+
+// Inc increments the gauge's value by one.
+func (g *StandardGaugeFloat64) Inc() {
+	g.Add(1)
 }
 
 // Snapshot returns a read-only copy of the gauge.
@@ -80,14 +136,31 @@ func (g *StandardGaugeFloat64) Snapshot() GaugeFloat64 {
 
 // Update updates the gauge's value.
 func (g *StandardGaugeFloat64) Update(v float64) {
-	g.mutex.Lock()
-	g.value = v
-	g.mutex.Unlock()
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
 }
 
 // Value returns the gauge's current value.
 func (g *StandardGaugeFloat64) Value() float64 {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
-	return g.value
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// FunctionalGaugeFloat64 returns the value of a function every time it is
+// read, for gauges mirroring a value kept elsewhere.
+type FunctionalGaugeFloat64 struct {
+	value func() float64
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *FunctionalGaugeFloat64) Snapshot() GaugeFloat64 {
+	return GaugeFloat64Snapshot(g.Value())
+}
+
+// Update panics.
+func (*FunctionalGaugeFloat64) Update(float64) {
+	panic("Update called on a FunctionalGaugeFloat64")
+}
+
+// Value returns the current value of the function.
+func (g *FunctionalGaugeFloat64) Value() float64 {
+	return g.value()
 }
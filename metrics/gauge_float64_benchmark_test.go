@@ -9,3 +9,41 @@ func BenchmarkGaugeFloat64(b *testing.B) {
 		g.Update(float64(i))
 	}
 }
+
+func BenchmarkGaugeFloat64Parallel(b *testing.B) {
+	g := NewGaugeFloat64()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			g.Update(float64(i))
+		}
+	})
+}
+
+func BenchmarkGaugeFloat64Value(b *testing.B) {
+	g := NewGaugeFloat64()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Value()
+		}
+	})
+}
+
+func BenchmarkGaugeFloat64Add(b *testing.B) {
+	g := NewGaugeFloat64().(*StandardGaugeFloat64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Add(0.5)
+	}
+}
+
+func BenchmarkGaugeFloat64AddParallel(b *testing.B) {
+	g := NewGaugeFloat64().(*StandardGaugeFloat64)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Add(0.5)
+		}
+	})
+}
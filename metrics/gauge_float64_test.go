@@ -1,12 +1,16 @@
 package metrics
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 // Check the interfaces are satisfied
 func TestGaugeFloat64_impl(t *testing.T) {
 	var _ GaugeFloat64 = new(NilGaugeFloat64)
 	var _ GaugeFloat64 = new(GaugeFloat64Snapshot)
 	var _ GaugeFloat64 = new(StandardGaugeFloat64)
+	var _ GaugeFloat64 = new(FunctionalGaugeFloat64)
 }
 
 func TestGaugeFloat64(t *testing.T) {
@@ -35,3 +39,77 @@ func TestGetOrRegisterGaugeFloat64(t *testing.T) {
 		t.Fatal(g)
 	}
 }
+
+func TestGaugeFloat64Add(t *testing.T) {
+	g := NewGaugeFloat64().(*StandardGaugeFloat64)
+	g.Update(float64(47.0))
+	g.Add(0.5)
+	g.Inc()
+	g.Dec()
+	g.Dec()
+	if v := g.Value(); float64(46.5) != v {
+		t.Errorf("g.Value(): 46.5 != %v\n", v)
+	}
+}
+
+func TestGaugeFloat64AddConcurrent(t *testing.T) {
+	g := NewGaugeFloat64().(*StandardGaugeFloat64)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				g.Add(0.5)
+			}
+		}()
+	}
+	wg.Wait()
+	if v := g.Value(); float64(4000.0) != v {
+		t.Errorf("g.Value(): 4000.0 != %v\n", v)
+	}
+}
+
+func TestGaugeFloat64CompareAndSwap(t *testing.T) {
+	g := NewGaugeFloat64().(*StandardGaugeFloat64)
+	g.Update(float64(47.0))
+	if g.CompareAndSwap(1.0, 2.0) {
+		t.Error("g.CompareAndSwap(1.0, 2.0): swapped")
+	}
+	if !g.CompareAndSwap(47.0, 74.0) {
+		t.Error("g.CompareAndSwap(47.0, 74.0): not swapped")
+	}
+	if v := g.Value(); float64(74.0) != v {
+		t.Errorf("g.Value(): 74.0 != %v\n", v)
+	}
+}
+
+func TestFunctionalGaugeFloat64(t *testing.T) {
+	var counter float64
+	fg := NewFunctionalGaugeFloat64(func() float64 {
+		counter++
+		return counter
+	})
+	fg.Value()
+	fg.Value()
+	if 2 != counter {
+		t.Error("counter != 2")
+	}
+	snapshot := fg.Snapshot()
+	if v := snapshot.Value(); 3 != v {
+		t.Errorf("snapshot.Value(): 3 != %v\n", v)
+	}
+	snapshot.Value()
+	if 3 != counter {
+		t.Error("counter != 3")
+	}
+}
+
+func TestGetOrRegisterFunctionalGaugeFloat64(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredFunctionalGaugeFloat64("foo", r, func() float64 { return 47 })
+	g := GetOrRegisterFunctionalGaugeFloat64("foo", r, func() float64 { return 74 })
+	if 47 != g.Value() {
+		t.Fatal(g)
+	}
+}
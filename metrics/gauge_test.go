@@ -1,7 +1,9 @@
 package metrics
 
 import (
+	"bytes"
 	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -10,6 +12,7 @@ func TestGauge_impl(t *testing.T) {
 	var _ Gauge = new(NilGauge)
 	var _ Gauge = new(GaugeSnapshot)
 	var _ Gauge = new(StandardGauge)
+	var _ Gauge = new(FunctionalGauge)
 }
 
 func TestGauge(t *testing.T) {
@@ -44,3 +47,78 @@ func ExampleGetOrRegisterGauge() {
 	g.Update(47)
 	fmt.Println(g.Value()) // Output: 47
 }
+
+func TestFunctionalGauge(t *testing.T) {
+	var counter int64
+	fg := NewFunctionalGauge(func() int64 {
+		counter++
+		return counter
+	})
+	fg.Value()
+	fg.Value()
+	if 2 != counter {
+		t.Error("counter != 2")
+	}
+	snapshot := fg.Snapshot()
+	if v := snapshot.Value(); 3 != v {
+		t.Errorf("snapshot.Value(): 3 != %v\n", v)
+	}
+	snapshot.Value()
+	if 3 != counter {
+		t.Error("counter != 3")
+	}
+}
+
+func TestGetOrRegisterFunctionalGauge(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredFunctionalGauge("foo", r, func() int64 { return 47 })
+	g := GetOrRegisterFunctionalGauge("foo", r, func() int64 { return 74 })
+	if 47 != g.Value() {
+		t.Fatal(g)
+	}
+}
+
+func TestWritePrometheusFunctionalGauge(t *testing.T) {
+	r := NewRegistry()
+	queue := []int{1, 2, 3}
+	GetOrRegisterFunctionalGauge("queue_length", r, func() int64 { return int64(len(queue)) })
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); "# TYPE queue_length gauge\nqueue_length 3\n" != s {
+		t.Fatal(s)
+	}
+}
+
+func TestGaugeAdd(t *testing.T) {
+	g := NewGauge()
+	g.Update(int64(47))
+	g.Add(5)
+	g.Inc()
+	g.Dec()
+	g.Dec()
+	if v := g.Value(); 51 != v {
+		t.Errorf("g.Value(): 51 != %v\n", v)
+	}
+}
+
+func TestGaugeAddConcurrent(t *testing.T) {
+	g := NewGauge()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				g.Inc()
+				g.Add(2)
+				g.Dec()
+			}
+		}()
+	}
+	wg.Wait()
+	if v := g.Value(); 16000 != v {
+		t.Errorf("g.Value(): 16000 != %v\n", v)
+	}
+}
@@ -0,0 +1,199 @@
+package metrics
+
+import "sync/atomic"
+
+// WatermarkGauge is a Gauge which also records the highest and lowest values
+// it held since it was last reset, such as the peak number of requests in
+// flight between two flushes of a reporter.  Only SnapshotAndReset resets
+// it; snapshots of the registry holding it, which exporters take on every
+// read, leave it unchanged, so that several exporters of one registry all
+// see the same extremes.
+type WatermarkGauge interface {
+	Gauge
+
+	Max() int64
+	Min() int64
+	SnapshotAndReset() WatermarkGauge
+}
+
+// GetOrRegisterWatermarkGauge returns an existing WatermarkGauge or constructs
+// and registers a new StandardWatermarkGauge.
+func GetOrRegisterWatermarkGauge(name string, r Registry) WatermarkGauge {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return mustGetOrRegisterAs(r, name, NewWatermarkGauge)
+}
+
+// NewWatermarkGauge constructs a new StandardWatermarkGauge.
+func NewWatermarkGauge() WatermarkGauge {
+	if UseNilMetrics {
+		return NilWatermarkGauge{}
+	}
+	return &StandardWatermarkGauge{}
+}
+
+// NewRegisteredWatermarkGauge constructs and registers a new
+// StandardWatermarkGauge.
+func NewRegisteredWatermarkGauge(name string, r Registry) WatermarkGauge {
+	c := NewWatermarkGauge()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// WatermarkGaugeSnapshot is a read-only copy of another WatermarkGauge.
+type WatermarkGaugeSnapshot struct {
+	value, max, min int64
+}
+
+// Add panics.
+func (*WatermarkGaugeSnapshot) Add(int64) {
+	panic("Add called on a WatermarkGaugeSnapshot")
+}
+
+// Dec panics.
+func (*WatermarkGaugeSnapshot) Dec() {
+	panic("Dec called on a WatermarkGaugeSnapshot")
+}
+
+// Inc panics.
+func (*WatermarkGaugeSnapshot) Inc() {
+	panic("Inc called on a WatermarkGaugeSnapshot")
+}
+
+// Max returns the highest value at the time the snapshot was taken.
+func (g *WatermarkGaugeSnapshot) Max() int64 { return g.max }
+
+// Min returns the lowest value at the time the snapshot was taken.
+func (g *WatermarkGaugeSnapshot) Min() int64 { return g.min }
+
+// Snapshot returns the snapshot.
+func (g *WatermarkGaugeSnapshot) Snapshot() Gauge { return g }
+
+// SnapshotAndReset panics.
+func (*WatermarkGaugeSnapshot) SnapshotAndReset() WatermarkGauge {
+	panic("SnapshotAndReset called on a WatermarkGaugeSnapshot")
+}
+
+// Update panics.
+func (*WatermarkGaugeSnapshot) Update(int64) {
+	panic("Update called on a WatermarkGaugeSnapshot")
+}
+
+// Value returns the value at the time the snapshot was taken.
+func (g *WatermarkGaugeSnapshot) Value() int64 { return g.value }
+
+// NilWatermarkGauge is a no-op WatermarkGauge.
+type NilWatermarkGauge struct{}
+
+// Add is a no-op.
+func (NilWatermarkGauge) Add(delta int64) {}
+
+// Dec is a no-op.
+func (NilWatermarkGauge) Dec() {}
+
+// Inc is a no-op.
+func (NilWatermarkGauge) Inc() {}
+
+// Max is a no-op.
+func (NilWatermarkGauge) Max() int64 { return 0 }
+
+// Min is a no-op.
+func (NilWatermarkGauge) Min() int64 { return 0 }
+
+// Snapshot is a no-op.
+func (NilWatermarkGauge) Snapshot() Gauge { return NilWatermarkGauge{} }
+
+// SnapshotAndReset is a no-op.
+func (NilWatermarkGauge) SnapshotAndReset() WatermarkGauge { return NilWatermarkGauge{} }
+
+// Update is a no-op.
+func (NilWatermarkGauge) Update(v int64) {}
+
+// Value is a no-op.
+func (NilWatermarkGauge) Value() int64 { return 0 }
+
+// StandardWatermarkGauge is the standard implementation of a WatermarkGauge
+// and uses the sync/atomic package to manage its int64 values.
+type StandardWatermarkGauge struct {
+	value, max, min int64
+}
+
+// Add adds the given delta to the gauge's value.
+func (g *StandardWatermarkGauge) Add(delta int64) {
+	g.mark(atomic.AddInt64(&g.value, delta))
+}
+
+// Dec decrements the gauge's value by one.
+func (g *StandardWatermarkGauge) Dec() {
+	g.Add(-1)
+}
+
+// Inc increments the gauge's value by one.
+func (g *StandardWatermarkGauge) Inc() {
+	g.Add(1)
+}
+
+// Max returns the highest value since the gauge was last reset.
+func (g *StandardWatermarkGauge) Max() int64 {
+	return atomic.LoadInt64(&g.max)
+}
+
+// Min returns the lowest value since the gauge was last reset.
+func (g *StandardWatermarkGauge) Min() int64 {
+	return atomic.LoadInt64(&g.min)
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *StandardWatermarkGauge) Snapshot() Gauge {
+	return &WatermarkGaugeSnapshot{
+		value: g.Value(),
+		max:   g.Max(),
+		min:   g.Min(),
+	}
+}
+
+// SnapshotAndReset returns a read-only copy of the gauge and resets its
+// highest and lowest values to its current value.  The watermarks are
+// swapped atomically, so that no extreme is lost.
+func (g *StandardWatermarkGauge) SnapshotAndReset() WatermarkGauge {
+	v := g.Value()
+	snapshot := &WatermarkGaugeSnapshot{
+		value: v,
+		max:   atomic.SwapInt64(&g.max, v),
+		min:   atomic.SwapInt64(&g.min, v),
+	}
+	// an update racing with the swaps belongs to the next interval
+	g.mark(g.Value())
+	return snapshot
+}
+
+// Update updates the gauge's value.
+func (g *StandardWatermarkGauge) Update(v int64) {
+	atomic.StoreInt64(&g.value, v)
+	g.mark(v)
+}
+
+// Value returns the gauge's current value.
+func (g *StandardWatermarkGauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// mark raises the highest or lowers the lowest value to v if needed.
+func (g *StandardWatermarkGauge) mark(v int64) {
+	for {
+		max := atomic.LoadInt64(&g.max)
+		if v <= max || atomic.CompareAndSwapInt64(&g.max, max, v) {
+			break
+		}
+	}
+	for {
+		min := atomic.LoadInt64(&g.min)
+		if v >= min || atomic.CompareAndSwapInt64(&g.min, min, v) {
+			break
+		}
+	}
+}
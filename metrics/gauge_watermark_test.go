@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Check the interfaces are satisfied
+func TestWatermarkGauge_impl(t *testing.T) {
+	var _ WatermarkGauge = new(NilWatermarkGauge)
+	var _ WatermarkGauge = new(WatermarkGaugeSnapshot)
+	var _ WatermarkGauge = new(StandardWatermarkGauge)
+}
+
+func TestWatermarkGauge(t *testing.T) {
+	g := NewWatermarkGauge()
+	g.Inc()
+	g.Add(46)
+	g.Update(-3)
+	g.Inc()
+	if v := g.Value(); -2 != v {
+		t.Errorf("g.Value(): -2 != %v\n", v)
+	}
+	if max := g.Max(); 47 != max {
+		t.Errorf("g.Max(): 47 != %v\n", max)
+	}
+	if min := g.Min(); -3 != min {
+		t.Errorf("g.Min(): -3 != %v\n", min)
+	}
+}
+
+func TestWatermarkGaugeSnapshotAndReset(t *testing.T) {
+	g := NewWatermarkGauge()
+	g.Update(47)
+	g.Update(5)
+	snapshot := g.SnapshotAndReset()
+	if max := snapshot.Max(); 47 != max {
+		t.Errorf("snapshot.Max(): 47 != %v\n", max)
+	}
+	if min := snapshot.Min(); 0 != min {
+		t.Errorf("snapshot.Min(): 0 != %v\n", min)
+	}
+	if max, min := g.Max(), g.Min(); 5 != max || 5 != min {
+		t.Errorf("g.Max(), g.Min(): 5, 5 != %v, %v\n", max, min)
+	}
+	g.Inc()
+	if max := g.Max(); 6 != max {
+		t.Errorf("g.Max(): 6 != %v\n", max)
+	}
+}
+
+func TestWatermarkGaugeSnapshot(t *testing.T) {
+	g := NewWatermarkGauge()
+	g.Update(47)
+	snapshot := g.Snapshot().(WatermarkGauge)
+	g.Update(74)
+	if v, max := snapshot.Value(), snapshot.Max(); 47 != v || 47 != max {
+		t.Errorf("snapshot.Value(), snapshot.Max(): 47, 47 != %v, %v\n", v, max)
+	}
+	if max := g.Max(); 74 != max {
+		t.Errorf("g.Max(): 74 != %v\n", max)
+	}
+}
+
+func TestGetOrRegisterWatermarkGauge(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredWatermarkGauge("foo", r).Update(47)
+	if g := GetOrRegisterWatermarkGauge("foo", r); 47 != g.Max() {
+		t.Fatal(g)
+	}
+}
+
+func TestWritePrometheusWatermarkGauge(t *testing.T) {
+	r := NewRegistry()
+	g := GetOrRegisterWatermarkGauge("in_flight", r)
+	g.Add(3)
+	g.Dec()
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	expected := "# TYPE in_flight gauge\nin_flight 2\n" +
+		"# TYPE in_flight_max gauge\nin_flight_max 3\n" +
+		"# TYPE in_flight_min gauge\nin_flight_min 0\n"
+	if s := buf.String(); expected != s {
+		t.Fatal(s)
+	}
+	// scrapes do not reset the extremes, only SnapshotAndReset does
+	buf.Reset()
+	if err := WritePrometheus(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); expected != s {
+		t.Fatal(s)
+	}
+	g.SnapshotAndReset()
+	buf.Reset()
+	if err := WritePrometheus(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	expected = "# TYPE in_flight gauge\nin_flight 2\n" +
+		"# TYPE in_flight_max gauge\nin_flight_max 2\n" +
+		"# TYPE in_flight_min gauge\nin_flight_min 2\n"
+	if s := buf.String(); expected != s {
+		t.Fatal(s)
+	}
+}
+
+func TestRegistrySnapshotWatermarkGaugeNested(t *testing.T) {
+	r := NewRegistry()
+	mm := NewRegisteredMultiMetric("pool", nil, r)
+	g := mm.GetOrAdd("in_flight", NewWatermarkGauge()).(WatermarkGauge)
+	g.Add(10)
+	g.Add(-8)
+	for i := 0; i < 2; i++ {
+		if max := SnapshotRegistry(r).MultiMetric("pool").Metrics()["in_flight"].(WatermarkGauge).Max(); 10 != max {
+			t.Errorf("max: 10 != %v\n", max)
+		}
+	}
+	if max := g.Max(); 10 != max {
+		t.Errorf("g.Max(): 10 != %v\n", max)
+	}
+}
+
+func TestDeltaSnapshotterWatermarkGauge(t *testing.T) {
+	r := NewRegistry()
+	g := GetOrRegisterWatermarkGauge("in_flight", r)
+	d := NewDeltaSnapshotter(r)
+	g.Add(10)
+	g.Add(-8)
+	if max := d.Snapshot().Get("in_flight").(WatermarkGauge).Max(); 10 != max {
+		t.Errorf("max: 10 != %v\n", max)
+	}
+	if max := d.Snapshot().Get("in_flight").(WatermarkGauge).Max(); 10 != max {
+		t.Errorf("max: 10 != %v\n", max)
+	}
+	if max := g.Max(); 10 != max {
+		t.Errorf("g.Max(): 10 != %v\n", max)
+	}
+}
@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GraphiteConfig provides a container with configuration parameters for the
+// Graphite reporter.
+type GraphiteConfig struct {
+	Addr          string        // TCP address of the Carbon plaintext listener
+	Registry      Registry      // Registry to be exported
+	FlushInterval time.Duration // Flush interval
+	DurationUnit  time.Duration // Time conversion unit for durations
+	Prefix        string        // Prefix to be prepended to metric names
+	Percentiles   []float64     // Percentiles to export from histograms and timers
+	Timeout       time.Duration // Timeout for connecting and for each write, 5s if zero
+}
+
+// GraphiteReporter periodically writes the metrics of a registry to Graphite
+// using the plaintext protocol.  MultiMetric members are written as
+// "<name>.<member>", and MetricVec children and tagged metrics as
+// "<name>.<value1>.<value2>", with tag values in the order of their keys.
+// Whitespace in metric names is replaced with underscores.  The connection is
+// established lazily and re-established on the next flush after a failure.
+type GraphiteReporter struct {
+	config GraphiteConfig
+	conn   net.Conn
+}
+
+// NewGraphiteReporter constructs a new GraphiteReporter.
+func NewGraphiteReporter(c GraphiteConfig) *GraphiteReporter {
+	if nil == c.Registry {
+		c.Registry = DefaultRegistry
+	}
+	if 0 == c.FlushInterval {
+		c.FlushInterval = time.Minute
+	}
+	if 0 == c.DurationUnit {
+		c.DurationUnit = time.Nanosecond
+	}
+	if nil == c.Percentiles {
+		c.Percentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+	}
+	if 0 == c.Timeout {
+		c.Timeout = 5 * time.Second
+	}
+	return &GraphiteReporter{config: c}
+}
+
+// Close closes the connection to Graphite, if any.
+func (r *GraphiteReporter) Close() error {
+	if nil == r.conn {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// Run flushes the registry on every flush interval until the context is
+// cancelled.
+func (r *GraphiteReporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				log.Printf("ERROR metrics: graphite: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush writes the current state of the registry to Graphite, connecting
+// first if there is no open connection.  The connection is closed on failure
+// so that the next flush reconnects.
+func (r *GraphiteReporter) Flush() error {
+	var buf bytes.Buffer
+	r.write(&buf, SnapshotRegistry(r.config.Registry))
+	if nil == r.conn {
+		conn, err := net.DialTimeout("tcp", r.config.Addr, r.config.Timeout)
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+	}
+	if err := r.conn.SetWriteDeadline(time.Now().Add(r.config.Timeout)); err != nil {
+		r.Close()
+		return err
+	}
+	if _, err := r.conn.Write(buf.Bytes()); err != nil {
+		r.Close()
+		return err
+	}
+	return nil
+}
+
+func (r *GraphiteReporter) write(buf *bytes.Buffer, s *RegistrySnapshot) {
+	w := bufio.NewWriter(buf)
+	ts := s.Time().Unix()
+	for _, e := range s.entries {
+		path := graphiteNameEscaper.Replace(e.name)
+		if "" != r.config.Prefix {
+			path = graphiteNameEscaper.Replace(r.config.Prefix) + "." + path
+		}
+		keys := make([]string, 0, len(e.tags))
+		for k := range e.tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			path += "." + graphitePathEscaper.Replace(e.tags[k])
+		}
+		r.writeMetric(w, path, e.metric, ts)
+	}
+	w.Flush()
+}
+
+func (r *GraphiteReporter) writeMetric(w *bufio.Writer, path string, m Metric, ts int64) {
+	du := float64(r.config.DurationUnit)
+	switch metric := m.(type) {
+	case Counter:
+		fmt.Fprintf(w, "%s.count %d %d\n", path, metric.Count(), ts)
+	case WatermarkGauge:
+		fmt.Fprintf(w, "%s.value %d %d\n", path, metric.Value(), ts)
+		fmt.Fprintf(w, "%s.max %d %d\n", path, metric.Max(), ts)
+		fmt.Fprintf(w, "%s.min %d %d\n", path, metric.Min(), ts)
+	case Gauge:
+		fmt.Fprintf(w, "%s.value %d %d\n", path, metric.Value(), ts)
+	case GaugeFloat64:
+		fmt.Fprintf(w, "%s.value %f %d\n", path, metric.Value(), ts)
+	case Histogram:
+		h := metric.Snapshot()
+		ps := h.Percentiles(r.config.Percentiles)
+		fmt.Fprintf(w, "%s.count %d %d\n", path, h.Count(), ts)
+		fmt.Fprintf(w, "%s.min %d %d\n", path, h.Min(), ts)
+		fmt.Fprintf(w, "%s.max %d %d\n", path, h.Max(), ts)
+		fmt.Fprintf(w, "%s.mean %.2f %d\n", path, h.Mean(), ts)
+		fmt.Fprintf(w, "%s.std-dev %.2f %d\n", path, h.StdDev(), ts)
+		for i, p := range r.config.Percentiles {
+			fmt.Fprintf(w, "%s.%s-percentile %.2f %d\n", path, formatPercentile(p), ps[i], ts)
+		}
+	case Meter:
+		m := metric.Snapshot()
+		fmt.Fprintf(w, "%s.count %d %d\n", path, m.Count(), ts)
+		fmt.Fprintf(w, "%s.one-minute %.2f %d\n", path, m.Rate1(), ts)
+		fmt.Fprintf(w, "%s.five-minute %.2f %d\n", path, m.Rate5(), ts)
+		fmt.Fprintf(w, "%s.fifteen-minute %.2f %d\n", path, m.Rate15(), ts)
+		fmt.Fprintf(w, "%s.mean %.2f %d\n", path, m.RateMean(), ts)
+	case Timer:
+		t := metric.Snapshot()
+		ps := t.Percentiles(r.config.Percentiles)
+		fmt.Fprintf(w, "%s.count %d %d\n", path, t.Count(), ts)
+		fmt.Fprintf(w, "%s.min %d %d\n", path, t.Min()/int64(du), ts)
+		fmt.Fprintf(w, "%s.max %d %d\n", path, t.Max()/int64(du), ts)
+		fmt.Fprintf(w, "%s.mean %.2f %d\n", path, t.Mean()/du, ts)
+		fmt.Fprintf(w, "%s.std-dev %.2f %d\n", path, t.StdDev()/du, ts)
+		for i, p := range r.config.Percentiles {
+			fmt.Fprintf(w, "%s.%s-percentile %.2f %d\n", path, formatPercentile(p), ps[i]/du, ts)
+		}
+		fmt.Fprintf(w, "%s.one-minute %.2f %d\n", path, t.Rate1(), ts)
+		fmt.Fprintf(w, "%s.five-minute %.2f %d\n", path, t.Rate5(), ts)
+		fmt.Fprintf(w, "%s.fifteen-minute %.2f %d\n", path, t.Rate15(), ts)
+		fmt.Fprintf(w, "%s.mean-rate %.2f %d\n", path, t.RateMean(), ts)
+	case MultiMetric:
+		members := metric.Snapshot().Metrics()
+		keys := make([]string, 0, len(members))
+		for k := range members {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			r.writeMetric(w, path+"."+graphiteNameEscaper.Replace(k), members[k], ts)
+		}
+	case MetricVec:
+		labelNames := metric.LabelNames()
+		metric.Each(func(labels map[string]string, m Metric) {
+			childPath := path
+			for _, l := range labelNames {
+				childPath += "." + graphitePathEscaper.Replace(labels[l])
+			}
+			r.writeMetric(w, childPath, m, ts)
+		})
+	}
+}
+
+var (
+	graphiteNameEscaper = strings.NewReplacer(" ", "_", "\t", "_", "\r", "_", "\n", "_")
+	graphitePathEscaper = strings.NewReplacer(".", "_", " ", "_", "\t", "_", "\r", "_", "\n", "_")
+)
+
+// formatPercentile formats a percentile for metric names and paths, e.g. 0.5
+// as "50" and 0.999 as "99_9".  It is rounded to four decimal places, so that
+// values like 0.07, which are not exact in binary, are not formatted as
+// "7_000000000000001".
+func formatPercentile(p float64) string {
+	s := strconv.FormatFloat(p*100, 'f', 4, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return strings.Replace(s, ".", "_", 1)
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGraphiteReporterWrite(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(47)
+	NewRegisteredGaugeFloat64("gauge", r).Update(0.5)
+	h := NewRegisteredHistogram("histogram", r, NewUniformSample(100))
+	h.Update(1)
+	h.Update(3)
+	tm := NewRegisteredTimer("timer", r)
+	defer tm.Stop()
+	tm.Update(2 * time.Millisecond)
+	mm := NewRegisteredMultiMetric("http", map[string]string{"method": "GET"}, r)
+	mm.GetOrAdd("hits", NewCounter()).(Counter).Inc(2)
+	NewRegisteredGauge("bad name\n", r).Update(1)
+
+	reporter := NewGraphiteReporter(GraphiteConfig{
+		Registry:     r,
+		Prefix:       "app",
+		DurationUnit: time.Millisecond,
+		Percentiles:  []float64{0.5, 0.999},
+	})
+	s := SnapshotRegistry(r)
+	s.time = time.Unix(1500000000, 0)
+	var buf bytes.Buffer
+	reporter.write(&buf, s)
+
+	lines := map[string]bool{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		lines[scanner.Text()] = true
+	}
+	for _, line := range []string{
+		"app.counter.count 47 1500000000",
+		"app.gauge.value 0.500000 1500000000",
+		"app.histogram.count 2 1500000000",
+		"app.histogram.min 1 1500000000",
+		"app.histogram.max 3 1500000000",
+		"app.histogram.mean 2.00 1500000000",
+		"app.histogram.50-percentile 2.00 1500000000",
+		"app.histogram.99_9-percentile 3.00 1500000000",
+		"app.http.hits.count 2 1500000000",
+		"app.bad_name_.value 1 1500000000",
+		"app.timer.count 1 1500000000",
+		"app.timer.max 2 1500000000",
+		"app.timer.50-percentile 2.00 1500000000",
+	} {
+		if !lines[line] {
+			t.Errorf("missing line %q in:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestGraphiteReporterReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received <- scanner.Text()
+				}
+			}(conn)
+		}
+	}()
+
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r).Update(1)
+	reporter := NewGraphiteReporter(GraphiteConfig{
+		Addr:     ln.Addr().String(),
+		Registry: r,
+	})
+	defer reporter.Close()
+
+	if err := reporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-received; "foo.value 1 " != line[:len("foo.value 1 ")] {
+		t.Errorf("line: %q\n", line)
+	}
+
+	// break the connection; the next flush fails and the one after reconnects
+	reporter.conn.Close()
+	if err := reporter.Flush(); nil == err {
+		t.Fatal("expected error writing to a closed connection")
+	}
+	if nil != reporter.conn {
+		t.Fatal("connection was not reset after failure")
+	}
+	if err := reporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-received; "foo.value 1 " != line[:len("foo.value 1 ")] {
+		t.Errorf("line: %q\n", line)
+	}
+}
+
+func TestFormatPercentile(t *testing.T) {
+	for p, expected := range map[float64]string{
+		0.07:   "7",
+		0.5:    "50",
+		0.999:  "99_9",
+		0.9999: "99_99",
+		1:      "100",
+	} {
+		if s := formatPercentile(p); expected != s {
+			t.Errorf("formatPercentile(%v): %q != %q\n", p, expected, s)
+		}
+	}
+}
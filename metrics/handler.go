@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ExpositionHandler returns an http.Handler that serves the metrics in the
+// given registry as OpenMetrics, Prometheus text or JSON, depending on the
+// Accept header of the request.  Prometheus text is served when the header is
+// missing or names none of the supported media types.
+func ExpositionHandler(r Registry) http.Handler {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		contentType := negotiateContentType(req.Header.Get("Accept"))
+
+		var write func(io.Writer, Registry) error
+		switch contentType {
+		case OpenMetricsContentType:
+			write = WriteOpenMetrics
+		case JSONContentType:
+			write = WriteJSONOnce
+		default:
+			write = WritePrometheus
+		}
+
+		var buf bytes.Buffer
+		if err := write(&buf, r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Add("Vary", "Accept")
+		w.Write(buf.Bytes())
+	})
+}
+
+// negotiateContentType returns the supported content type preferred by the
+// given Accept header.  Among media ranges of equal quality, the one listed
+// first wins.
+func negotiateContentType(accept string) string {
+	best, bestQ := PrometheusContentType, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		var contentType string
+		switch mediaType {
+		case "application/openmetrics-text":
+			contentType = OpenMetricsContentType
+		case "text/plain", "text/*", "*/*":
+			contentType = PrometheusContentType
+		case "application/json":
+			contentType = JSONContentType
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = contentType, q
+		}
+	}
+	return best
+}
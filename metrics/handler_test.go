@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpositionHandler(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r).Update(1)
+
+	testCases := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", PrometheusContentType},
+		{"*/*", PrometheusContentType},
+		{"text/plain;version=0.0.4", PrometheusContentType},
+		{"application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1", OpenMetricsContentType},
+		{"application/openmetrics-text;q=0.3,application/json", JSONContentType},
+		{"application/xml", PrometheusContentType},
+	}
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if "" != testCase.accept {
+			req.Header.Set("Accept", testCase.accept)
+		}
+		w := httptest.NewRecorder()
+		ExpositionHandler(r).ServeHTTP(w, req)
+		if ct := w.Header().Get("Content-Type"); testCase.contentType != ct {
+			t.Errorf("Accept %q: %s != %s\n", testCase.accept, testCase.contentType, ct)
+		}
+		body := w.Body.String()
+		switch testCase.contentType {
+		case OpenMetricsContentType:
+			if !strings.HasSuffix(body, "# EOF\n") {
+				t.Errorf("Accept %q: body not terminated by # EOF: %q\n", testCase.accept, body)
+			}
+		case JSONContentType:
+			var data map[string]map[string]int64
+			if err := json.Unmarshal([]byte(body), &data); nil != err {
+				t.Fatal(err)
+			}
+			if 1 != data["foo"]["value"] {
+				t.Errorf("Accept %q: body %q\n", testCase.accept, body)
+			}
+		default:
+			if "# TYPE foo gauge\nfoo 1\n" != body {
+				t.Errorf("Accept %q: body %q\n", testCase.accept, body)
+			}
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package metrics
 
+import "time"
+
 // Histogram calculates distribution statistics from a series of int64 values.
 type Histogram interface {
 	Metric
@@ -25,7 +27,35 @@ func GetOrRegisterHistogram(name string, r Registry, s Sample) Histogram {
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, func() Histogram { return NewHistogram(s) }).(Histogram)
+	return mustGetOrRegisterAs(r, name, func() Histogram { return NewHistogram(s) })
+}
+
+// GetOrRegisterBucketHistogram returns an existing Histogram or constructs and
+// registers a new StandardHistogram from a StandardBucketSample with the
+// given upper bounds.
+func GetOrRegisterBucketHistogram(name string, r Registry, bounds []float64) Histogram {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return mustGetOrRegisterAs(r, name, func() Histogram { return NewBucketHistogram(bounds) })
+}
+
+// NewBucketHistogram constructs a new StandardHistogram from a
+// StandardBucketSample with the given upper bounds.  Its buckets are exported
+// as Prometheus histogram buckets.
+func NewBucketHistogram(bounds []float64) Histogram {
+	return NewHistogram(NewBucketSample(bounds))
+}
+
+// NewRegisteredBucketHistogram constructs and registers a new
+// StandardHistogram from a StandardBucketSample with the given upper bounds.
+func NewRegisteredBucketHistogram(name string, r Registry, bounds []float64) Histogram {
+	c := NewBucketHistogram(bounds)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
 }
 
 // NewHistogram constructs a new StandardHistogram from a Sample.
@@ -33,7 +63,7 @@ func NewHistogram(s Sample) Histogram {
 	if UseNilMetrics {
 		return NilHistogram{}
 	}
-	return &StandardHistogram{sample: s}
+	return &StandardHistogram{sample: s, created: time.Now()}
 }
 
 // NewRegisteredHistogram constructs and registers a new StandardHistogram from
@@ -49,7 +79,7 @@ func NewRegisteredHistogram(name string, r Registry, s Sample) Histogram {
 
 // HistogramSnapshot is a read-only copy of another Histogram.
 type HistogramSnapshot struct {
-	sample *SampleSnapshot
+	sample Sample
 }
 
 // Clear panics.
@@ -153,7 +183,8 @@ func (NilHistogram) Variance() float64 { return 0.0 }
 // StandardHistogram is the standard implementation of a Histogram and uses a
 // Sample to bound its memory use.
 type StandardHistogram struct {
-	sample Sample
+	sample  Sample
+	created time.Time
 }
 
 // Clear clears the histogram and its sample.
@@ -163,6 +194,9 @@ func (h *StandardHistogram) Clear() { h.sample.Clear() }
 // cleared.
 func (h *StandardHistogram) Count() int64 { return h.sample.Count() }
 
+// Created returns the time the histogram was constructed.
+func (h *StandardHistogram) Created() time.Time { return h.created }
+
 // Max returns the maximum value in the sample.
 func (h *StandardHistogram) Max() int64 { return h.sample.Max() }
 
@@ -188,7 +222,31 @@ func (h *StandardHistogram) Sample() Sample { return h.sample }
 
 // Snapshot returns a read-only copy of the histogram.
 func (h *StandardHistogram) Snapshot() Histogram {
-	return &HistogramSnapshot{sample: h.sample.Snapshot().(*SampleSnapshot)}
+	return &HistogramSnapshot{sample: h.sample.Snapshot()}
+}
+
+// SnapshotAndReset returns a read-only copy of the histogram and clears it.
+// Samples which implement SnapshotAndReset, like the ones in this package, do
+// so atomically, so that no update is lost; other samples are cleared after
+// the copy is taken.
+func (h *StandardHistogram) SnapshotAndReset() Histogram {
+	if s, ok := h.sample.(sampleResetter); ok {
+		return &HistogramSnapshot{sample: s.SnapshotAndReset()}
+	}
+	snapshot := h.Snapshot()
+	h.sample.Clear()
+	return snapshot
+}
+
+// snapshotAndResetHistogram returns a read-only copy of the given histogram
+// and clears it, atomically if it implements SnapshotAndReset.
+func snapshotAndResetHistogram(h Histogram) Histogram {
+	if r, ok := h.(interface{ SnapshotAndReset() Histogram }); ok {
+		return r.SnapshotAndReset()
+	}
+	snapshot := h.Snapshot()
+	h.Clear()
+	return snapshot
 }
 
 // StdDev returns the standard deviation of the values in the sample.
@@ -92,3 +92,22 @@ func testHistogram10000(t *testing.T, h Histogram) {
 		t.Errorf("99th percentile: 9900.99 != %v\n", ps[2])
 	}
 }
+
+func TestHistogramSnapshotAndReset(t *testing.T) {
+	for _, s := range []Sample{NewUniformSample(100), NewExpDecaySample(100, 0.99)} {
+		h := NewHistogram(s).(*StandardHistogram)
+		h.Update(1)
+		h.Update(2)
+		snapshot := h.SnapshotAndReset()
+		h.Update(10)
+		if count := snapshot.Count(); 2 != count {
+			t.Errorf("snapshot.Count(): 2 != %v\n", count)
+		}
+		if max := snapshot.Max(); 2 != max {
+			t.Errorf("snapshot.Max(): 2 != %v\n", max)
+		}
+		if count := h.Count(); 1 != count {
+			t.Errorf("h.Count(): 1 != %v\n", count)
+		}
+	}
+}
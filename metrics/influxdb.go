@@ -0,0 +1,324 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteInfluxDB writes the metrics in the given registry to w in the InfluxDB
+// line protocol, one line per metric, timestamped with ts in nanoseconds.
+//
+// Every metric becomes a measurement named after it.  A MultiMetric becomes a
+// single measurement with its tags as tags and one field per member; members
+// with several values, such as Histograms, get one field per value named
+// "<member>_<value>".  Every child of a MetricVec becomes a line of the
+// measurement with its labels as tags.  The tags of metrics in a
+// TaggedRegistry are written as tags.
+func WriteInfluxDB(w io.Writer, r Registry, ts time.Time) error {
+	bw := bufio.NewWriter(w)
+	for _, line := range influxDBLines(r, ts) {
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// influxDBLines returns the line protocol lines for the metrics in the given
+// registry, sorted by measurement.
+func influxDBLines(r Registry, ts time.Time) []string {
+	entries := SnapshotRegistry(r).entries
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name, tags := e.name, e.tags
+		if vec, ok := e.metric.(MetricVec); ok {
+			vec.Each(func(labels map[string]string, m Metric) {
+				if line := influxDBLine(name, mergeTags(tags, labels), influxDBFields(m), ts); "" != line {
+					lines = append(lines, line)
+				}
+			})
+			continue
+		}
+		fields := make(map[string]interface{})
+		if mm, ok := e.metric.(MultiMetric); ok {
+			mm = mm.Snapshot()
+			tags = mergeTags(tags, mm.Tags())
+			for member, m := range mm.Metrics() {
+				mf := influxDBFields(m)
+				if 1 == len(mf) {
+					for _, v := range mf {
+						fields[member] = v
+					}
+					continue
+				}
+				for k, v := range mf {
+					fields[member+"_"+k] = v
+				}
+			}
+		} else {
+			fields = influxDBFields(e.metric)
+		}
+		if line := influxDBLine(name, tags, fields, ts); "" != line {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// influxDBFields returns the field values of a single metric.
+func influxDBFields(m Metric) map[string]interface{} {
+	switch metric := m.(type) {
+	case Counter:
+		return map[string]interface{}{"count": metric.Count()}
+	case WatermarkGauge:
+		return map[string]interface{}{
+			"value": metric.Value(),
+			"max":   metric.Max(),
+			"min":   metric.Min(),
+		}
+	case Gauge:
+		return map[string]interface{}{"value": metric.Value()}
+	case GaugeFloat64:
+		return map[string]interface{}{"value": metric.Value()}
+	case Histogram:
+		h := metric.Snapshot()
+		ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		return map[string]interface{}{
+			"count":  h.Count(),
+			"min":    h.Min(),
+			"max":    h.Max(),
+			"mean":   h.Mean(),
+			"stddev": h.StdDev(),
+			"p50":    ps[0],
+			"p75":    ps[1],
+			"p95":    ps[2],
+			"p99":    ps[3],
+			"p999":   ps[4],
+		}
+	case Meter:
+		m := metric.Snapshot()
+		return map[string]interface{}{
+			"count": m.Count(),
+			"m1":    m.Rate1(),
+			"m5":    m.Rate5(),
+			"m15":   m.Rate15(),
+			"mean":  m.RateMean(),
+		}
+	case Timer:
+		t := metric.Snapshot()
+		ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		return map[string]interface{}{
+			"count":    t.Count(),
+			"min":      t.Min(),
+			"max":      t.Max(),
+			"mean":     t.Mean(),
+			"stddev":   t.StdDev(),
+			"p50":      ps[0],
+			"p75":      ps[1],
+			"p95":      ps[2],
+			"p99":      ps[3],
+			"p999":     ps[4],
+			"m1":       t.Rate1(),
+			"m5":       t.Rate5(),
+			"m15":      t.Rate15(),
+			"meanrate": t.RateMean(),
+		}
+	}
+	return nil
+}
+
+// influxDBLine formats a single line.  Fields which cannot be represented,
+// such as NaN, are dropped; an empty string is returned if no field is left.
+func influxDBLine(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) string {
+	fieldKeys := make([]string, 0, len(fields))
+	for k, v := range fields {
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			continue
+		}
+		fieldKeys = append(fieldKeys, k)
+	}
+	if 0 == len(fieldKeys) {
+		return ""
+	}
+	sort.Strings(fieldKeys)
+	tagKeys := make([]string, 0, len(tags))
+	for k := range tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+
+	var b strings.Builder
+	b.WriteString(influxDBMeasurementEscaper.Replace(measurement))
+	for _, k := range tagKeys {
+		if "" == tags[k] {
+			continue
+		}
+		b.WriteByte(',')
+		b.WriteString(influxDBKeyEscaper.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(influxDBKeyEscaper.Replace(tags[k]))
+	}
+	for i, k := range fieldKeys {
+		if 0 == i {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(influxDBKeyEscaper.Replace(k))
+		b.WriteByte('=')
+		switch v := fields[k].(type) {
+		case int64:
+			b.WriteString(strconv.FormatInt(v, 10))
+			b.WriteByte('i')
+		case float64:
+			b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(ts.UnixNano(), 10))
+	return b.String()
+}
+
+var (
+	influxDBMeasurementEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `, "\n", `\n`)
+	influxDBKeyEscaper         = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// InfluxDBConfig provides a container with configuration parameters for the
+// InfluxDB reporter.
+type InfluxDBConfig struct {
+	URL           string        // URL of the /write endpoint, including the query string
+	Registry      Registry      // Registry to be exported
+	FlushInterval time.Duration // Flush interval
+	BatchSize     int           // Maximum number of lines per request
+	MaxRetries    int           // Number of retries of a failed request
+	RetryBackoff  time.Duration // Delay before the first retry, doubled on every retry
+	Client        *http.Client  // HTTP client, http.DefaultClient if nil
+}
+
+// InfluxDBReporter periodically POSTs the metrics of a registry in the line
+// protocol to an InfluxDB or Telegraf /write endpoint.  Request bodies are
+// gzip compressed.
+type InfluxDBReporter struct {
+	config InfluxDBConfig
+}
+
+// NewInfluxDBReporter constructs a new InfluxDBReporter.
+func NewInfluxDBReporter(c InfluxDBConfig) *InfluxDBReporter {
+	if nil == c.Registry {
+		c.Registry = DefaultRegistry
+	}
+	if 0 == c.FlushInterval {
+		c.FlushInterval = 10 * time.Second
+	}
+	if 0 == c.BatchSize {
+		c.BatchSize = 5000
+	}
+	if 0 == c.RetryBackoff {
+		c.RetryBackoff = time.Second
+	}
+	if nil == c.Client {
+		c.Client = http.DefaultClient
+	}
+	return &InfluxDBReporter{config: c}
+}
+
+// Run flushes the registry on every flush interval until the context is
+// cancelled.
+func (r *InfluxDBReporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(ctx); err != nil {
+				log.Printf("ERROR metrics: influxdb: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush sends the current state of the registry in batches of at most
+// BatchSize lines.
+func (r *InfluxDBReporter) Flush(ctx context.Context) error {
+	lines := influxDBLines(r.config.Registry, time.Now())
+	for len(lines) > 0 {
+		n := r.config.BatchSize
+		if n > len(lines) {
+			n = len(lines)
+		}
+		if err := r.send(ctx, lines[:n]); err != nil {
+			return err
+		}
+		lines = lines[n:]
+	}
+	return nil
+}
+
+// send POSTs a batch, retrying on network errors and server errors.
+func (r *InfluxDBReporter) send(ctx context.Context, lines []string) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	for _, line := range lines {
+		io.WriteString(zw, line)
+		io.WriteString(zw, "\n")
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	backoff := r.config.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+		}
+		var retry bool
+		if retry, err = r.post(ctx, body.Bytes()); nil == err || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// post makes a single request and reports whether a failure is worth
+// retrying.
+func (r *InfluxDBReporter) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", r.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := r.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+		fmt.Errorf("unexpected response %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
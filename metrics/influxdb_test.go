@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteInfluxDB(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("requests", r).Inc(47)
+	NewRegisteredGaugeFloat64("cpu load", r).Update(0.5)
+	mm := NewRegisteredMultiMetric("http,server", map[string]string{"path": "/a b", "method": "GET"}, r)
+	mm.GetOrAdd("hits", NewCounter()).(Counter).Inc(2)
+	mm.GetOrAdd("size", NewGauge()).(Gauge).Update(512)
+
+	var buf bytes.Buffer
+	if err := WriteInfluxDB(&buf, r, time.Unix(1500000000, 5)); nil != err {
+		t.Fatal(err)
+	}
+	expected := `cpu\ load value=0.5 1500000000000000005
+http\,server,method=GET,path=/a\ b hits=2i,size=512i 1500000000000000005
+requests count=47i 1500000000000000005
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("WriteInfluxDB():\n%s\n!=\n%s", expected, s)
+	}
+}
+
+func TestWriteInfluxDBHistogramMember(t *testing.T) {
+	r := NewRegistry()
+	mm := NewRegisteredMultiMetric("db", map[string]string{}, r)
+	mm.GetOrAdd("latency", NewHistogram(NewUniformSample(100))).(Histogram).Update(3)
+
+	var buf bytes.Buffer
+	if err := WriteInfluxDB(&buf, r, time.Unix(0, 0)); nil != err {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"latency_count=1i", "latency_max=3i", "latency_p99=3"} {
+		if !strings.Contains(buf.String(), field) {
+			t.Errorf("missing field %q in %q\n", field, buf.String())
+		}
+	}
+}
+
+func TestInfluxDBReporter(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if 1 == attempts {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if "gzip" != req.Header.Get("Content-Encoding") {
+			t.Errorf("Content-Encoding: %q\n", req.Header.Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, _ := ioutil.ReadAll(zr)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	r := NewRegistry()
+	NewRegisteredCounter("a", r).Inc(1)
+	NewRegisteredCounter("b", r).Inc(2)
+	NewRegisteredCounter("c", r).Inc(3)
+
+	reporter := NewInfluxDBReporter(InfluxDBConfig{
+		URL:          ts.URL + "/write?db=metrics",
+		Registry:     r,
+		BatchSize:    2,
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+	})
+	if err := reporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if 3 != attempts {
+		t.Errorf("attempts: 3 != %d\n", attempts)
+	}
+	if 2 != len(bodies) {
+		t.Fatalf("len(bodies): 2 != %d\n", len(bodies))
+	}
+	if !strings.HasPrefix(bodies[0], "a count=1i ") || 2 != strings.Count(bodies[0], "\n") {
+		t.Errorf("bodies[0]: %q\n", bodies[0])
+	}
+	if !strings.HasPrefix(bodies[1], "c count=3i ") || 1 != strings.Count(bodies[1], "\n") {
+		t.Errorf("bodies[1]: %q\n", bodies[1])
+	}
+}
+
+func TestInfluxDBReporterClientError(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		http.Error(w, "bad line", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	r := NewRegistry()
+	NewRegisteredCounter("a", r).Inc(1)
+
+	reporter := NewInfluxDBReporter(InfluxDBConfig{
+		URL:          ts.URL + "/write",
+		Registry:     r,
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+	if err := reporter.Flush(context.Background()); nil == err {
+		t.Fatal("expected error")
+	}
+	if 1 != attempts {
+		t.Errorf("attempts: 1 != %d\n", attempts)
+	}
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// JSONContentType is the Content-Type of the JSON written by WriteJSONOnce.
+const JSONContentType = "application/json; charset=utf-8"
+
+// MarshalJSON returns a byte slice containing a JSON representation of all
+// the metrics in the registry.
+func (r *StandardRegistry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(registryJSON(r))
+}
+
+// JSONHandler returns an http.Handler that serves the metrics in the given
+// registry as JSON, suitable for mounting at /debug/metrics.
+func JSONHandler(r Registry) http.Handler {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var buf bytes.Buffer
+		if err := WriteJSONOnce(&buf, r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", JSONContentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+// WriteJSON writes the metrics in the given registry to w as JSON on every
+// interval d until the context is cancelled.
+func WriteJSON(ctx context.Context, w io.Writer, r Registry, d time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := WriteJSONOnce(w, r); err != nil {
+				log.Printf("ERROR metrics: json: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WriteJSONOnce writes the metrics in the given registry to w as a JSON
+// object keyed by metric name.  Counters are written as {"count": n}, gauges
+// as {"value": v}, histograms with their count, min, max, mean, stddev and
+// percentiles, and with the cumulative counts of their buckets keyed by upper
+// bound under "buckets" if they have any, MultiMetrics as {"tags": {...},
+// "metrics": {...}}, and MetricVecs as {"children": [...]} with the labels of
+// every child under "labels".  NaN and infinite values, which JSON cannot
+// represent, are written as the strings "NaN", "+Inf" and "-Inf".
+func WriteJSONOnce(w io.Writer, r Registry) error {
+	return json.NewEncoder(w).Encode(registryJSON(r))
+}
+
+// registryJSON returns the values of every metric in the registry keyed by
+// metric name, or by "name{k1=v1,k2=v2}" for tagged metrics.
+func registryJSON(r Registry) map[string]interface{} {
+	data := make(map[string]interface{})
+	for _, e := range SnapshotRegistry(r).entries {
+		if values := metricJSON(e.metric); nil != values {
+			data[formatTaggedName(e.name, e.tags)] = values
+		}
+	}
+	return data
+}
+
+// metricJSON returns the values of a single metric, or nil if the metric is
+// of an unknown type.
+func metricJSON(m Metric) map[string]interface{} {
+	values := make(map[string]interface{})
+	switch metric := m.(type) {
+	case Counter:
+		values["count"] = metric.Count()
+	case WatermarkGauge:
+		values["value"] = metric.Value()
+		values["max"] = metric.Max()
+		values["min"] = metric.Min()
+	case Gauge:
+		values["value"] = metric.Value()
+	case GaugeFloat64:
+		values["value"] = jsonFloat(metric.Value())
+	case Histogram:
+		h := metric.Snapshot()
+		ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = h.Count()
+		values["min"] = h.Min()
+		values["max"] = h.Max()
+		values["mean"] = jsonFloat(h.Mean())
+		values["stddev"] = jsonFloat(h.StdDev())
+		values["median"] = jsonFloat(ps[0])
+		values["75%"] = jsonFloat(ps[1])
+		values["95%"] = jsonFloat(ps[2])
+		values["99%"] = jsonFloat(ps[3])
+		values["99.9%"] = jsonFloat(ps[4])
+		if b, ok := h.Sample().(BucketSample); ok {
+			bounds, counts := b.Buckets()
+			buckets := make(map[string]int64, len(bounds)+1)
+			for i, bound := range bounds {
+				buckets[formatPrometheusFloat(bound)] = counts[i]
+			}
+			buckets["+Inf"] = b.Count()
+			values["buckets"] = buckets
+		}
+	case Meter:
+		m := metric.Snapshot()
+		values["count"] = m.Count()
+		values["1m.rate"] = jsonFloat(m.Rate1())
+		values["5m.rate"] = jsonFloat(m.Rate5())
+		values["15m.rate"] = jsonFloat(m.Rate15())
+		values["mean.rate"] = jsonFloat(m.RateMean())
+	case Timer:
+		t := metric.Snapshot()
+		ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = t.Count()
+		values["min"] = t.Min()
+		values["max"] = t.Max()
+		values["mean"] = jsonFloat(t.Mean())
+		values["stddev"] = jsonFloat(t.StdDev())
+		values["median"] = jsonFloat(ps[0])
+		values["75%"] = jsonFloat(ps[1])
+		values["95%"] = jsonFloat(ps[2])
+		values["99%"] = jsonFloat(ps[3])
+		values["99.9%"] = jsonFloat(ps[4])
+		values["1m.rate"] = jsonFloat(t.Rate1())
+		values["5m.rate"] = jsonFloat(t.Rate5())
+		values["15m.rate"] = jsonFloat(t.Rate15())
+		values["mean.rate"] = jsonFloat(t.RateMean())
+	case MultiMetric:
+		mm := metric.Snapshot()
+		metrics := make(map[string]interface{})
+		for k, v := range mm.Metrics() {
+			if mv := metricJSON(v); nil != mv {
+				metrics[k] = mv
+			}
+		}
+		values["tags"] = mm.Tags()
+		values["metrics"] = metrics
+	case MetricVec:
+		children := make([]interface{}, 0)
+		metric.Each(func(labels map[string]string, m Metric) {
+			if cv := metricJSON(m); nil != cv {
+				cv["labels"] = labels
+				children = append(children, cv)
+			}
+		})
+		values["children"] = children
+	default:
+		return nil
+	}
+	return values
+}
+
+// jsonFloat returns the given value, or its string representation if it is
+// NaN or infinite, which JSON cannot represent.
+func jsonFloat(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return formatPrometheusFloat(v)
+	}
+	return v
+}
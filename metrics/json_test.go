@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegistryMarshalJSON(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(1)
+	b, err := json.Marshal(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if s := string(b); "{\"counter\":{\"count\":1}}" != s {
+		t.Fatal(s)
+	}
+}
+
+func TestWriteJSONOnce(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGauge("gauge", r).Update(47)
+	h := NewRegisteredHistogram("histogram", r, NewUniformSample(100))
+	h.Update(1)
+	h.Update(3)
+	mm := NewRegisteredMultiMetric("http", map[string]string{"method": "GET"}, r)
+	mm.GetOrAdd("hits", NewCounter()).(Counter).Inc(2)
+
+	var buf bytes.Buffer
+	if err := WriteJSONOnce(&buf, r); nil != err {
+		t.Fatal(err)
+	}
+	var data map[string]map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &data); nil != err {
+		t.Fatal(err)
+	}
+	if v := data["gauge"]["value"]; 47.0 != v {
+		t.Errorf("gauge.value: 47 != %v\n", v)
+	}
+	for k, expected := range map[string]float64{"count": 2, "min": 1, "max": 3, "mean": 2, "stddev": 1, "median": 2} {
+		if v := data["histogram"][k]; expected != v {
+			t.Errorf("histogram.%s: %v != %v\n", k, expected, v)
+		}
+	}
+	if tags := data["http"]["tags"].(map[string]interface{}); "GET" != tags["method"] {
+		t.Errorf("http.tags: %v\n", tags)
+	}
+	hits := data["http"]["metrics"].(map[string]interface{})["hits"].(map[string]interface{})
+	if 2.0 != hits["count"] {
+		t.Errorf("http.metrics.hits: %v\n", hits)
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(1)
+
+	w := httptest.NewRecorder()
+	JSONHandler(r).ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); JSONContentType != ct {
+		t.Errorf("Content-Type: %s != %s\n", JSONContentType, ct)
+	}
+	if body := w.Body.String(); "{\"foo\":{\"count\":1}}\n" != body {
+		t.Errorf("body: %q\n", body)
+	}
+}
+
+func TestJSONHandlerNonFinite(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGaugeFloat64("nan", r).Update(math.NaN())
+	NewRegisteredGaugeFloat64("inf", r).Update(math.Inf(-1))
+
+	w := httptest.NewRecorder()
+	JSONHandler(r).ServeHTTP(w, httptest.NewRequest("GET", "/debug/metrics", nil))
+	if 200 != w.Code {
+		t.Fatalf("w.Code: 200 != %v: %s\n", w.Code, w.Body.String())
+	}
+	if body := w.Body.String(); "{\"inf\":{\"value\":\"-Inf\"},\"nan\":{\"value\":\"NaN\"}}\n" != body {
+		t.Errorf("body: %q\n", body)
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Len()
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(1)
+
+	var buf syncBuffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	WriteJSON(ctx, &buf, r, 5*time.Millisecond)
+	if 0 == buf.Len() {
+		t.Error("nothing written")
+	}
+}
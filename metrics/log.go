@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zbiljic/pkg/logger"
+)
+
+// LogConfig provides a container with configuration parameters for the log
+// reporter.
+type LogConfig struct {
+	Registry      Registry      // Registry to be logged
+	Logger        *log.Logger   // Logger to write to, typically from logger.NewLogger
+	FlushInterval time.Duration // Flush interval
+	Level         logger.Level  // Level of every line, logger.LevelInfo if zero
+	Percentiles   []float64     // Percentiles to log from histograms and timers
+}
+
+// LogReporter periodically writes one line per metric of a registry to a
+// logger.  Every line starts with the configured level so that a
+// logger.LevelFilter filters it like any other line.  Counters and Meters
+// also log the delta since the previous flush.  Tags, MultiMetric tags and
+// MetricVec labels are logged as a single "name{k1=v1,k2=v2}".
+type LogReporter struct {
+	config LogConfig
+	last   map[string]int64 // counts logged on the previous flush
+	seen   map[string]int64 // counts logged on the current flush
+}
+
+// NewLogReporter constructs a new LogReporter.  It panics if the level is not
+// one of the levels of the logger package.
+func NewLogReporter(c LogConfig) *LogReporter {
+	if nil == c.Registry {
+		c.Registry = DefaultRegistry
+	}
+	if nil == c.Logger {
+		c.Logger = log.New(log.Writer(), "", 0)
+	}
+	if 0 == c.FlushInterval {
+		c.FlushInterval = time.Minute
+	}
+	if 0 == c.Level {
+		c.Level = logger.LevelInfo
+	}
+	if "" == c.Level.String() {
+		panic(fmt.Sprintf("metrics: unknown log level %d", c.Level))
+	}
+	if nil == c.Percentiles {
+		c.Percentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+	}
+	return &LogReporter{
+		config: c,
+		last:   make(map[string]int64),
+	}
+}
+
+// Run flushes the registry on every flush interval until the context is
+// cancelled.
+func (r *LogReporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush writes the current state of the registry to the logger.  The counts
+// of metrics which are no longer in the registry are forgotten.
+func (r *LogReporter) Flush() {
+	r.seen = make(map[string]int64, len(r.last))
+	for _, e := range SnapshotRegistry(r.config.Registry).entries {
+		r.log(e.name, e.tags, e.metric)
+	}
+	r.last, r.seen = r.seen, nil
+}
+
+func (r *LogReporter) log(name string, tags map[string]string, m Metric) {
+	key := formatTaggedName(name, tags)
+	switch metric := m.(type) {
+	case Counter:
+		count := metric.Count()
+		r.printf("counter", key, "count=%d delta=%d", count, r.delta(key, count))
+	case WatermarkGauge:
+		r.printf("gauge", key, "value=%d max=%d min=%d", metric.Value(), metric.Max(), metric.Min())
+	case Gauge:
+		r.printf("gauge", key, "value=%d", metric.Value())
+	case GaugeFloat64:
+		r.printf("gauge", key, "value=%f", metric.Value())
+	case Histogram:
+		h := metric.Snapshot()
+		r.printf("histogram", key, "count=%d min=%d max=%d mean=%.2f stddev=%.2f%s",
+			h.Count(), h.Min(), h.Max(), h.Mean(), h.StdDev(),
+			r.percentiles(h.Percentiles(r.config.Percentiles), func(v float64) string {
+				return fmt.Sprintf("%.2f", v)
+			}))
+	case Meter:
+		m := metric.Snapshot()
+		count := m.Count()
+		r.printf("meter", key, "count=%d delta=%d rate1=%.2f rate5=%.2f rate15=%.2f rate.mean=%.2f",
+			count, r.delta(key, count), m.Rate1(), m.Rate5(), m.Rate15(), m.RateMean())
+	case Timer:
+		t := metric.Snapshot()
+		count := t.Count()
+		r.printf("timer", key, "count=%d delta=%d min=%s max=%s mean=%s stddev=%s%s rate1=%.2f rate5=%.2f rate15=%.2f rate.mean=%.2f",
+			count, r.delta(key, count),
+			time.Duration(t.Min()), time.Duration(t.Max()),
+			time.Duration(t.Mean()), time.Duration(t.StdDev()),
+			r.percentiles(t.Percentiles(r.config.Percentiles), func(v float64) string {
+				return time.Duration(v).String()
+			}),
+			t.Rate1(), t.Rate5(), t.Rate15(), t.RateMean())
+	case MultiMetric:
+		mm := metric.Snapshot()
+		mmTags := mergeTags(tags, mm.Tags())
+		mm.Each(func(k string, m Metric) {
+			r.log(name+"."+k, mmTags, m)
+		})
+	case MetricVec:
+		metric.Each(func(labels map[string]string, m Metric) {
+			r.log(name, mergeTags(tags, labels), m)
+		})
+	}
+}
+
+func (r *LogReporter) printf(kind, name, format string, v ...interface{}) {
+	r.config.Logger.Printf("%s %s %s: %s", r.config.Level, kind, name, fmt.Sprintf(format, v...))
+}
+
+// delta returns the difference between the given count and the count logged
+// on the previous flush under the same name.
+func (r *LogReporter) delta(name string, count int64) int64 {
+	delta := count - r.last[name]
+	r.seen[name] = count
+	return delta
+}
+
+func (r *LogReporter) percentiles(ps []float64, format func(float64) string) string {
+	var b strings.Builder
+	for i, p := range r.config.Percentiles {
+		fmt.Fprintf(&b, " p%s=%s", formatPercentile(p), format(ps[i]))
+	}
+	return b.String()
+}
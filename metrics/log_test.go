@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbiljic/pkg/logger"
+)
+
+func TestLogReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRegistry()
+	c := NewRegisteredCounter("counter", r)
+	c.Inc(5)
+	NewRegisteredGauge("gauge", r).Update(47)
+	h := NewRegisteredHistogram("histogram", r, NewUniformSample(100))
+	h.Update(1)
+	h.Update(3)
+	mm := NewRegisteredMultiMetric("http", map[string]string{"method": "GET"}, r)
+	mm.GetOrAdd("hits", NewCounter()).(Counter).Inc(2)
+
+	reporter := NewLogReporter(LogConfig{
+		Registry:    r,
+		Logger:      log.New(&buf, "", 0),
+		Level:       logger.LevelDebug,
+		Percentiles: []float64{0.5, 0.999},
+	})
+	reporter.Flush()
+	expected := `DEBUG counter counter: count=5 delta=5
+DEBUG gauge gauge: value=47
+DEBUG histogram histogram: count=2 min=1 max=3 mean=2.00 stddev=1.00 p50=2.00 p99_9=3.00
+DEBUG counter http.hits{method=GET}: count=2 delta=2
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("Flush():\n%s\n!=\n%s", expected, s)
+	}
+
+	buf.Reset()
+	c.Inc(3)
+	reporter.Flush()
+	if s := buf.String(); !strings.HasPrefix(s, "DEBUG counter counter: count=8 delta=3\n") {
+		t.Errorf("Flush(): %q\n", s)
+	}
+
+	r.Unregister("counter")
+	reporter.Flush()
+	if _, ok := reporter.last["counter"]; ok {
+		t.Errorf("reporter.last: %v\n", reporter.last)
+	}
+}
+
+func TestLogReporterRun(t *testing.T) {
+	var buf syncBuffer
+	r := NewRegistry()
+	NewRegisteredGauge("gauge", r).Update(1)
+
+	reporter := NewLogReporter(LogConfig{
+		Registry:      r,
+		Logger:        log.New(&buf, "", 0),
+		FlushInterval: 5 * time.Millisecond,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	reporter.Run(ctx)
+	if 0 == buf.Len() {
+		t.Error("nothing logged")
+	}
+}
+
+func TestLogReporterLevelFilter(t *testing.T) {
+	var buf bytes.Buffer
+	filter := logger.NewLevelFilter(logger.LevelInfo)
+	filter.SetLogOutput(&buf)
+	r := NewRegistry()
+	NewRegisteredGauge("gauge", r).Update(1)
+
+	NewLogReporter(LogConfig{
+		Registry: r,
+		Logger:   log.New(filter, "", 0),
+		Level:    logger.LevelDebug,
+	}).Flush()
+	if 0 != buf.Len() {
+		t.Errorf("DEBUG line not filtered: %q\n", buf.String())
+	}
+
+	NewLogReporter(LogConfig{
+		Registry: r,
+		Logger:   log.New(filter, "", 0),
+		Level:    logger.LevelWarn,
+	}).Flush()
+	if s := buf.String(); !strings.HasSuffix(s, " WARN gauge gauge: value=1\n") {
+		t.Errorf("WARN line: %q\n", s)
+	}
+}
+
+func TestLogReporterTags(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRegistry().(TaggedRegistry)
+	mm := NewMultiMetric(map[string]string{"method": "GET"})
+	mm.GetOrAdd("hits", NewCounter()).(Counter).Inc(2)
+	r.RegisterTagged("http", map[string]string{"host": "a"}, mm)
+	vec := NewCounterVec("code")
+	vec.WithLabelValues("200").Inc(1)
+	r.RegisterTagged("requests", map[string]string{"host": "a"}, vec)
+
+	NewLogReporter(LogConfig{
+		Registry: r,
+		Logger:   log.New(&buf, "", 0),
+	}).Flush()
+	expected := `INFO counter http.hits{host=a,method=GET}: count=2 delta=2
+INFO counter requests{code=200,host=a}: count=1 delta=1
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("Flush():\n%s\n!=\n%s", expected, s)
+	}
+}
+
+func TestLogReporterUnknownLevel(t *testing.T) {
+	defer func() {
+		if nil == recover() {
+			t.Error("NewLogReporter did not panic on an unknown level")
+		}
+	}()
+	NewLogReporter(LogConfig{Level: logger.Level(42)})
+}
@@ -0,0 +1,12 @@
+package metrics
+
+import "testing"
+
+func BenchmarkMeter(b *testing.B) {
+	m := NewMeter()
+	defer m.Stop()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Mark(1)
+	}
+}
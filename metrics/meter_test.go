@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+// Check the interfaces are satisfied
+func TestMeter_impl(t *testing.T) {
+	var _ Meter = new(NilMeter)
+	var _ Meter = new(MeterSnapshot)
+	var _ Meter = new(StandardMeter)
+}
+
+func TestGetOrRegisterMeter(t *testing.T) {
+	r := NewRegistry()
+	m := NewRegisteredMeter("foo", r)
+	defer m.Stop()
+	m.Mark(47)
+	if m := GetOrRegisterMeter("foo", r); 47 != m.Count() {
+		t.Fatal(m)
+	}
+}
+
+func TestMeterDecay(t *testing.T) {
+	m := newStandardMeter()
+	m.Mark(1)
+	m.tick()
+	rateMean := m.RateMean()
+	time.Sleep(100 * time.Millisecond)
+	if m.RateMean() >= rateMean {
+		t.Error("m.RateMean() didn't decrease")
+	}
+}
+
+func TestMeterNonzero(t *testing.T) {
+	m := NewMeter()
+	defer m.Stop()
+	m.Mark(3)
+	if count := m.Count(); 3 != count {
+		t.Errorf("m.Count(): 3 != %v\n", count)
+	}
+}
+
+func TestMeterRates(t *testing.T) {
+	m := newStandardMeter()
+	m.Mark(3)
+	m.tick()
+	if rate := m.Rate1(); 0.6 != rate {
+		t.Errorf("m.Rate1(): 0.6 != %v\n", rate)
+	}
+	if rate := m.Rate5(); 0.6 != rate {
+		t.Errorf("m.Rate5(): 0.6 != %v\n", rate)
+	}
+	if rate := m.Rate15(); 0.6 != rate {
+		t.Errorf("m.Rate15(): 0.6 != %v\n", rate)
+	}
+}
+
+func TestMeterSnapshot(t *testing.T) {
+	m := NewMeter()
+	defer m.Stop()
+	m.Mark(1)
+	snapshot := m.Snapshot()
+	m.Mark(1)
+	if count := snapshot.Count(); 1 != count {
+		t.Errorf("snapshot.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestMeterStop(t *testing.T) {
+	l := len(arbiter.meters)
+	m := NewMeter()
+	if len(arbiter.meters) != l+1 {
+		t.Errorf("arbiter.meters: %d != %d\n", l+1, len(arbiter.meters))
+	}
+	m.Stop()
+	if len(arbiter.meters) != l {
+		t.Errorf("arbiter.meters: %d != %d\n", l, len(arbiter.meters))
+	}
+	m.Mark(1)
+	if count := m.Count(); 0 != count {
+		t.Errorf("m.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestMeterZero(t *testing.T) {
+	m := NewMeter()
+	defer m.Stop()
+	if count := m.Count(); 0 != count {
+		t.Errorf("m.Count(): 0 != %v\n", count)
+	}
+}
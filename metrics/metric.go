@@ -2,3 +2,38 @@ package metrics
 
 // Metric is a tag interface to indicate that a struct is a metric.
 type Metric interface{}
+
+// Snapshotter is implemented by metrics which can take a read-only copy of
+// themselves.  Custom metric types implement it to be included in snapshots
+// of MultiMetrics and registries the same way as the built-in ones.
+type Snapshotter interface {
+	Metric
+
+	SnapshotMetric() Metric
+}
+
+// SnapshotMetric returns a read-only copy of the given metric.  Metrics which
+// implement Snapshotter, like the MetricVecs of this package, are copied with
+// SnapshotMetric and the other built-in metric types with their Snapshot
+// method.  Other metrics are returned as they are.
+func SnapshotMetric(m Metric) Metric {
+	switch metric := m.(type) {
+	case Snapshotter:
+		return metric.SnapshotMetric()
+	case Counter:
+		return metric.Snapshot()
+	case Gauge:
+		return metric.Snapshot()
+	case GaugeFloat64:
+		return metric.Snapshot()
+	case Histogram:
+		return metric.Snapshot()
+	case Meter:
+		return metric.Snapshot()
+	case Timer:
+		return metric.Snapshot()
+	case MultiMetric:
+		return metric.Snapshot()
+	}
+	return m
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"reflect"
+	"sort"
 	"sync"
 )
 
@@ -9,8 +10,13 @@ import (
 type MultiMetric interface {
 	Metric
 
+	DeleteTag(string)
+	Each(func(string, Metric))
 	GetOrAdd(string, Metric) Metric
+	Len() int
 	Metrics() map[string]Metric
+	Remove(string)
+	SetTag(string, string)
 	Snapshot() MultiMetric
 	Tags() map[string]string
 }
@@ -21,7 +27,7 @@ func GetOrRegisterMultiMetric(name string, tags map[string]string, r Registry) M
 	if nil == r {
 		r = DefaultRegistry
 	}
-	return r.GetOrRegister(name, func() MultiMetric { return NewMultiMetric(tags) }).(MultiMetric)
+	return mustGetOrRegisterAs(r, name, func() MultiMetric { return NewMultiMetric(tags) })
 }
 
 // NewMultiMetric constructs a new StandardMultiMetric.
@@ -31,7 +37,7 @@ func NewMultiMetric(tags map[string]string) MultiMetric {
 	}
 	return &StandardMultiMetric{
 		metrics: make(map[string]Metric),
-		tags:    tags,
+		tags:    mergeTags(tags, nil),
 	}
 }
 
@@ -50,16 +56,42 @@ type MultiMetricSnapshot struct {
 	m MultiMetric
 }
 
+// DeleteTag panics.
+func (mm *MultiMetricSnapshot) DeleteTag(key string) {
+	panic("DeleteTag called on a MultiMetricSnapshot")
+}
+
+// Each calls the given function for each metric in the multi metric, in
+// lexicographical order of names.
+func (mm *MultiMetricSnapshot) Each(fn func(string, Metric)) {
+	mm.m.Each(fn)
+}
+
 // GetOrAdd panics.
 func (mm *MultiMetricSnapshot) GetOrAdd(name string, m Metric) Metric {
 	panic("GetOrAdd called on a MultiMetricSnapshot")
 }
 
+// Len returns the number of metrics in the multi metric.
+func (mm *MultiMetricSnapshot) Len() int {
+	return mm.m.Len()
+}
+
 // Metrics returns all the metrics in the multi metric as a map.
 func (mm *MultiMetricSnapshot) Metrics() map[string]Metric {
 	return mm.m.Metrics()
 }
 
+// Remove panics.
+func (mm *MultiMetricSnapshot) Remove(name string) {
+	panic("Remove called on a MultiMetricSnapshot")
+}
+
+// SetTag panics.
+func (mm *MultiMetricSnapshot) SetTag(key, value string) {
+	panic("SetTag called on a MultiMetricSnapshot")
+}
+
 // Snapshot returns the snapshot.
 func (mm *MultiMetricSnapshot) Snapshot() MultiMetric {
 	return mm
@@ -73,12 +105,27 @@ func (mm *MultiMetricSnapshot) Tags() map[string]string {
 // NilMultiMetric is a no-op MultiMetric.
 type NilMultiMetric struct{}
 
+// DeleteTag is a no-op.
+func (NilMultiMetric) DeleteTag(key string) {}
+
+// Each is a no-op.
+func (NilMultiMetric) Each(fn func(string, Metric)) {}
+
 // GetOrAdd is a no-op.
 func (NilMultiMetric) GetOrAdd(name string, m Metric) Metric { return nil }
 
+// Len is a no-op.
+func (NilMultiMetric) Len() int { return 0 }
+
 // Metrics is a no-op.
 func (NilMultiMetric) Metrics() map[string]Metric { return map[string]Metric{} }
 
+// Remove is a no-op.
+func (NilMultiMetric) Remove(name string) {}
+
+// SetTag is a no-op.
+func (NilMultiMetric) SetTag(key, value string) {}
+
 // Snapshot is a no-op.
 func (NilMultiMetric) Snapshot() MultiMetric { return NilMultiMetric{} }
 
@@ -92,6 +139,28 @@ type StandardMultiMetric struct {
 	mu      sync.Mutex
 }
 
+// DeleteTag removes the tag with the given key from the multi metric.
+func (mm *StandardMultiMetric) DeleteTag(key string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	delete(mm.tags, key)
+}
+
+// Each calls the given function for each metric in the multi metric, in
+// lexicographical order of names.  The metrics are copied before the first
+// call, so the function may modify the multi metric.
+func (mm *StandardMultiMetric) Each(fn func(string, Metric)) {
+	metrics := mm.Metrics()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fn(name, metrics[name])
+	}
+}
+
 // GetOrAdd gets an existing metric or adds a new one to multi metric.
 func (mm *StandardMultiMetric) GetOrAdd(name string, m Metric) Metric {
 	mm.mu.Lock()
@@ -106,41 +175,62 @@ func (mm *StandardMultiMetric) GetOrAdd(name string, m Metric) Metric {
 	return m
 }
 
-// Metrics returns all the metrics in the multi metric as a map.
-//
-// Returned map should not be changed by the user.
+// Len returns the number of metrics in the multi metric.
+func (mm *StandardMultiMetric) Len() int {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return len(mm.metrics)
+}
+
+// Metrics returns a copy of the metrics in the multi metric as a map.
 func (mm *StandardMultiMetric) Metrics() map[string]Metric {
-	return mm.metrics
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	metrics := make(map[string]Metric, len(mm.metrics))
+	for k, v := range mm.metrics {
+		metrics[k] = v
+	}
+	return metrics
+}
+
+// Remove removes the metric with the given name from the multi metric.
+func (mm *StandardMultiMetric) Remove(name string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	delete(mm.metrics, name)
 }
 
-// Snapshot returns a read-only copy of the multi metric.
+// SetTag sets the tag with the given key to the given value.
+func (mm *StandardMultiMetric) SetTag(key, value string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if nil == mm.tags {
+		mm.tags = make(map[string]string)
+	}
+	mm.tags[key] = value
+}
+
+// Snapshot returns a read-only copy of the multi metric.  Every member is
+// copied with SnapshotMetric.
 func (mm *StandardMultiMetric) Snapshot() MultiMetric {
+	return mm.snapshotWith(SnapshotMetric).(MultiMetric)
+}
+
+// snapshotWith returns a read-only copy of the multi metric with every member
+// copied by the given function.
+func (mm *StandardMultiMetric) snapshotWith(snapshot func(Metric) Metric) Metric {
 	mm.mu.Lock()
 	defer mm.mu.Unlock()
 	metrics := make(map[string]Metric)
 	for k, v := range mm.metrics {
-		switch metric := v.(type) {
-		// WHEN NEW METRIC IS ADDED, CASE FOR IT MUST BE ADDED HERE ALSO.
-		case Counter:
-			metrics[k] = metric.Snapshot()
-		case Gauge:
-			metrics[k] = metric.Snapshot()
-		case GaugeFloat64:
-			metrics[k] = metric.Snapshot()
-		case Histogram:
-			metrics[k] = metric.Snapshot()
-		}
-	}
-	tags := make(map[string]string)
-	for k, v := range mm.tags {
-		tags[k] = v
+		metrics[k] = snapshot(v)
 	}
-	return &MultiMetricSnapshot{&StandardMultiMetric{metrics: metrics, tags: tags}}
+	return &MultiMetricSnapshot{&StandardMultiMetric{metrics: metrics, tags: mergeTags(mm.tags, nil)}}
 }
 
-// Tags returns tag map for the multi metric.
-//
-// Returned map should not be changed by the user.
+// Tags returns a copy of the tag map for the multi metric.
 func (mm *StandardMultiMetric) Tags() map[string]string {
-	return mm.tags
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return mergeTags(mm.tags, nil)
 }
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"strconv"
 	"testing"
 )
 
@@ -61,3 +62,108 @@ func TestMultiMetricSnapshot(t *testing.T) {
 	}
 
 }
+
+func TestMultiMetricSnapshotMeter(t *testing.T) {
+	mm := NewMultiMetric(map[string]string{})
+	m := NewMeter()
+	defer m.Stop()
+	mm.GetOrAdd("meter", m)
+	m.Mark(1)
+
+	snapshot := mm.Snapshot()
+	m.Mark(1)
+	switch v := snapshot.Metrics()["meter"].(type) {
+	case Meter:
+		if 1 != v.Count() {
+			t.Errorf("meter.Count(): 1 != %d\n", v.Count())
+		}
+	default:
+		t.Errorf("metric is not 'Meter': %v\n", v)
+	}
+}
+
+// customMetric is a metric type unknown to the package.
+type customMetric struct {
+	value int64
+}
+
+func (m *customMetric) SnapshotMetric() Metric {
+	return &customMetric{value: m.value}
+}
+
+func TestMultiMetricSnapshotSnapshotter(t *testing.T) {
+	mm := NewMultiMetric(map[string]string{})
+	m := &customMetric{value: 47}
+	mm.GetOrAdd("custom", m)
+	v := &struct{ value int }{47}
+	mm.GetOrAdd("plain", v)
+
+	snapshot := mm.Snapshot()
+	m.value = 74
+	switch v := snapshot.Metrics()["custom"].(type) {
+	case *customMetric:
+		if 47 != v.value {
+			t.Errorf("custom.value: 47 != %d\n", v.value)
+		}
+	default:
+		t.Errorf("metric is not '*customMetric': %v\n", v)
+	}
+	if m := snapshot.Metrics()["plain"]; m != v {
+		t.Errorf("metric without a snapshot was not kept: %v\n", m)
+	}
+}
+
+func TestMultiMetricEach(t *testing.T) {
+	mm := NewMultiMetric(nil)
+	mm.GetOrAdd("b", NewCounter())
+	mm.GetOrAdd("a", NewGauge())
+	if l := mm.Len(); 2 != l {
+		t.Errorf("mm.Len(): 2 != %d\n", l)
+	}
+
+	names := make([]string, 0)
+	mm.Each(func(name string, m Metric) {
+		names = append(names, name)
+		mm.Remove(name)
+	})
+	if 2 != len(names) || "a" != names[0] || "b" != names[1] {
+		t.Errorf("names: [a b] != %v\n", names)
+	}
+	if l := mm.Len(); 0 != l {
+		t.Errorf("mm.Len(): 0 != %d\n", l)
+	}
+}
+
+func TestMultiMetricTags(t *testing.T) {
+	tags := map[string]string{"host": "a"}
+	mm := NewMultiMetric(tags)
+	mm.SetTag("region", "eu")
+	mm.DeleteTag("host")
+	mm.Tags()["region"] = "us"
+
+	if _, ok := tags["region"]; ok {
+		t.Errorf("tags of the caller were changed: %v\n", tags)
+	}
+	if v := mm.Tags(); 1 != len(v) || "eu" != v["region"] {
+		t.Errorf("mm.Tags(): map[region:eu] != %v\n", v)
+	}
+}
+
+func TestMultiMetricConcurrent(t *testing.T) {
+	mm := NewMultiMetric(nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			mm.GetOrAdd(strconv.Itoa(i%10), NewCounter)
+			mm.SetTag("i", strconv.Itoa(i))
+			mm.Remove(strconv.Itoa((i + 5) % 10))
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		mm.Each(func(string, Metric) {})
+		mm.Tags()
+		mm.Len()
+	}
+	<-done
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// OpenMetricsContentType is the Content-Type of the OpenMetrics text format
+// written by WriteOpenMetrics.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// createdMetric is implemented by metrics which know when they were created.
+type createdMetric interface {
+	Created() time.Time
+}
+
+// WriteOpenMetrics writes the metrics in the given registry to w in the
+// OpenMetrics 1.0 text format.
+//
+// The mapping of metric types is the same as the one of WritePrometheus.
+// Counter samples carry the _total suffix, Timer families carry the seconds
+// unit, and counters and summaries whose metric knows its creation time get a
+// _created sample.  The exposition is terminated by "# EOF".
+func WriteOpenMetrics(w io.Writer, r Registry) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range collectPrometheus(r, true) {
+		bw.WriteString("# TYPE ")
+		bw.WriteString(f.name)
+		bw.WriteByte(' ')
+		bw.WriteString(f.typ)
+		bw.WriteByte('\n')
+		if "" != f.unit {
+			bw.WriteString("# UNIT ")
+			bw.WriteString(f.name)
+			bw.WriteByte(' ')
+			bw.WriteString(f.unit)
+			bw.WriteByte('\n')
+		}
+		for _, s := range f.samples {
+			bw.WriteString(f.name)
+			bw.WriteString(s.suffix)
+			writePrometheusLabels(bw, s.labels)
+			bw.WriteByte(' ')
+			bw.WriteString(formatPrometheusFloat(s.value))
+			bw.WriteByte('\n')
+		}
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// unixSeconds returns t as fractional seconds since the Unix epoch.
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(1e9)
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	r := NewRegistry()
+	r.Register("requests_total", &StandardCounter{count: 47, created: time.Unix(1500000000, 0)})
+	NewRegisteredGauge("queue", r).Update(3)
+	tm := NewCustomTimer(
+		&StandardHistogram{sample: NewUniformSample(100), created: time.Unix(1500000000, 0)},
+		NilMeter{},
+	).(*StandardTimer)
+	tm.created = time.Unix(1500000000, 500000000)
+	tm.Update(time.Second)
+	r.Register("latency", tm)
+
+	var buf bytes.Buffer
+	if err := WriteOpenMetrics(&buf, r); nil != err {
+		t.Fatal(err)
+	}
+	expected := `# TYPE latency_seconds summary
+# UNIT latency_seconds seconds
+latency_seconds{quantile="0.5"} 1
+latency_seconds{quantile="0.75"} 1
+latency_seconds{quantile="0.95"} 1
+latency_seconds{quantile="0.99"} 1
+latency_seconds{quantile="0.999"} 1
+latency_seconds_sum 1
+latency_seconds_count 1
+latency_seconds_created 1.5000000005e+09
+# TYPE queue gauge
+queue 3
+# TYPE requests counter
+requests_total 47
+requests_created 1.5e+09
+# EOF
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("WriteOpenMetrics():\n%s\n!=\n%s", expected, s)
+	}
+}
+
+func TestWriteOpenMetricsEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteOpenMetrics(&buf, NewRegistry()); nil != err {
+		t.Fatal(err)
+	}
+	if s := buf.String(); "# EOF\n" != s {
+		t.Errorf("WriteOpenMetrics(): %q\n", s)
+	}
+}
+
+func TestWriteOpenMetricsVecCreated(t *testing.T) {
+	r := NewRegistry()
+	v := NewRegisteredCounterVec("requests", r, "code")
+	c := v.WithLabelValues("200").(*StandardCounter)
+	c.created = time.Unix(1500000000, 0)
+	c.Inc(1)
+
+	var buf bytes.Buffer
+	if err := WriteOpenMetrics(&buf, r); nil != err {
+		t.Fatal(err)
+	}
+	expected := `# TYPE requests counter
+requests_total{code="200"} 1
+requests_created{code="200"} 1.5e+09
+# EOF
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("WriteOpenMetrics():\n%s\n!=\n%s", expected, s)
+	}
+}
@@ -0,0 +1,351 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrometheusContentType is the Content-Type of the Prometheus text exposition
+// format written by WritePrometheus.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PrometheusQuantiles are the quantiles exported for every Histogram and Timer
+// summary.
+var PrometheusQuantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// PrometheusHandler returns an http.Handler that serves the metrics in the
+// given registry in the Prometheus text exposition format.
+func PrometheusHandler(r Registry) http.Handler {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var buf bytes.Buffer
+		if err := WritePrometheus(&buf, r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", PrometheusContentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+// WritePrometheus writes the metrics in the given registry to w in the
+// Prometheus text exposition format, version 0.0.4.
+//
+// Counters and Meters are written as counters, Gauges and GaugeFloat64s as
+// gauges, WatermarkGauges as gauges with "<name>_max" and "<name>_min" gauges,
+// Histograms of a BucketSample as histograms with their buckets, and other
+// Histograms and Timers as summaries with PrometheusQuantiles, _sum and
+// _count samples.  Timer values are converted to seconds.  Every member of a
+// MultiMetric is written as "<name>_<member>" with the tags of the MultiMetric
+// as labels, and every child of a MetricVec as "<name>" with its labels.  The
+// tags of metrics in a TaggedRegistry are written as labels.  Metric families
+// are sorted by name.
+func WritePrometheus(w io.Writer, r Registry) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range collectPrometheus(r, false) {
+		bw.WriteString("# TYPE ")
+		bw.WriteString(f.name)
+		bw.WriteByte(' ')
+		bw.WriteString(f.typ)
+		bw.WriteByte('\n')
+		for _, s := range f.samples {
+			bw.WriteString(f.name)
+			bw.WriteString(s.suffix)
+			writePrometheusLabels(bw, s.labels)
+			bw.WriteByte(' ')
+			bw.WriteString(formatPrometheusFloat(s.value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// Prometheus metric types.
+const (
+	prometheusCounter   = "counter"
+	prometheusGauge     = "gauge"
+	prometheusHistogram = "histogram"
+	prometheusSummary   = "summary"
+)
+
+// prometheusFamily is a group of samples sharing a name and a type.
+type prometheusFamily struct {
+	name    string
+	typ     string
+	unit    string
+	samples []prometheusSample
+}
+
+// add appends a sample with the given suffix, tags and optional extra label
+// to the family.
+func (f *prometheusFamily) add(suffix string, tags map[string]string, extra *prometheusLabel, v float64) {
+	labels := make([]prometheusLabel, 0, len(tags)+1)
+	for k, v := range tags {
+		labels = append(labels, prometheusLabel{sanitizePrometheusLabelName(k), v})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].name < labels[j].name
+	})
+	var key strings.Builder
+	writePrometheusLabels(&key, labels)
+	if nil != extra {
+		labels = append(labels, *extra)
+	}
+	f.samples = append(f.samples, prometheusSample{
+		key:    key.String(),
+		suffix: suffix,
+		labels: labels,
+		value:  v,
+	})
+}
+
+// prometheusSample is a single line of the exposition.  The key is the
+// rendering of the labels without the quantile label and is used to keep
+// the lines of the same metric together.
+type prometheusSample struct {
+	key    string
+	suffix string
+	labels []prometheusLabel
+	value  float64
+}
+
+type prometheusLabel struct {
+	name, value string
+}
+
+// prometheusCollector converts metrics into metric families.  When
+// openMetrics is set, the families follow the OpenMetrics conventions for
+// counter suffixes, units and created timestamps.
+type prometheusCollector struct {
+	families    map[string]*prometheusFamily
+	openMetrics bool
+}
+
+// collectPrometheus converts the contents of a registry into metric families
+// sorted by name, with samples sorted by labels.
+func collectPrometheus(r Registry, openMetrics bool) []*prometheusFamily {
+	c := &prometheusCollector{
+		families:    make(map[string]*prometheusFamily),
+		openMetrics: openMetrics,
+	}
+	for _, e := range SnapshotRegistry(r).entries {
+		c.add(e.name, e.tags, e.metric, e.created)
+	}
+	families := make([]*prometheusFamily, 0, len(c.families))
+	for _, f := range c.families {
+		sort.SliceStable(f.samples, func(i, j int) bool {
+			return f.samples[i].key < f.samples[j].key
+		})
+		families = append(families, f)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+	return families
+}
+
+func (c *prometheusCollector) add(name string, tags map[string]string, m Metric, created time.Time) {
+	if cm, ok := m.(createdMetric); ok && created.IsZero() {
+		created = cm.Created()
+	}
+	switch metric := m.(type) {
+	case Counter:
+		c.counter(name, tags, float64(metric.Count()), created)
+	case WatermarkGauge:
+		c.add(name, tags, GaugeSnapshot(metric.Value()), created)
+		c.add(name+"_max", tags, GaugeSnapshot(metric.Max()), created)
+		c.add(name+"_min", tags, GaugeSnapshot(metric.Min()), created)
+	case Gauge:
+		if f := c.family(name, prometheusGauge, ""); nil != f {
+			f.add("", tags, nil, float64(metric.Value()))
+		}
+	case GaugeFloat64:
+		if f := c.family(name, prometheusGauge, ""); nil != f {
+			f.add("", tags, nil, metric.Value())
+		}
+	case Histogram:
+		h := metric.Snapshot()
+		if b, ok := h.Sample().(BucketSample); ok {
+			c.histogram(name, tags, b, created)
+			return
+		}
+		c.summary(name, "", tags, h.Percentiles(PrometheusQuantiles), float64(h.Sum()), h.Count(), created)
+	case Meter:
+		c.counter(name, tags, float64(metric.Count()), created)
+	case Timer:
+		t := metric.Snapshot()
+		ps := t.Percentiles(PrometheusQuantiles)
+		for i := range ps {
+			ps[i] /= float64(1e9)
+		}
+		c.summary(name, "seconds", tags, ps, float64(t.Sum())/float64(1e9), t.Count(), created)
+	case MultiMetric:
+		mm := metric.Snapshot()
+		merged := mergeTags(tags, mm.Tags())
+		for k, v := range mm.Metrics() {
+			c.add(name+"_"+k, merged, v, time.Time{})
+		}
+	case *MetricVecSnapshot:
+		metric.eachCreated(func(labels map[string]string, m Metric, created time.Time) {
+			c.add(name, mergeTags(tags, labels), m, created)
+		})
+	case MetricVec:
+		metric.Each(func(labels map[string]string, m Metric) {
+			c.add(name, mergeTags(tags, labels), m, time.Time{})
+		})
+	}
+}
+
+func (c *prometheusCollector) counter(name string, tags map[string]string, v float64, created time.Time) {
+	f := c.family(name, prometheusCounter, "")
+	if nil == f {
+		return
+	}
+	if !c.openMetrics {
+		f.add("", tags, nil, v)
+		return
+	}
+	f.add("_total", tags, nil, v)
+	if !created.IsZero() {
+		f.add("_created", tags, nil, unixSeconds(created))
+	}
+}
+
+func (c *prometheusCollector) summary(name, unit string, tags map[string]string, ps []float64, sum float64, count int64, created time.Time) {
+	f := c.family(name, prometheusSummary, unit)
+	if nil == f {
+		return
+	}
+	for i, q := range PrometheusQuantiles {
+		quantile := prometheusLabel{"quantile", formatPrometheusFloat(q)}
+		f.add("", tags, &quantile, ps[i])
+	}
+	f.add("_sum", tags, nil, sum)
+	f.add("_count", tags, nil, float64(count))
+	if c.openMetrics && !created.IsZero() {
+		f.add("_created", tags, nil, unixSeconds(created))
+	}
+}
+
+func (c *prometheusCollector) histogram(name string, tags map[string]string, b BucketSample, created time.Time) {
+	f := c.family(name, prometheusHistogram, "")
+	if nil == f {
+		return
+	}
+	bounds, counts := b.Buckets()
+	for i, bound := range bounds {
+		le := prometheusLabel{"le", formatPrometheusFloat(bound)}
+		f.add("_bucket", tags, &le, float64(counts[i]))
+	}
+	inf := prometheusLabel{"le", "+Inf"}
+	f.add("_bucket", tags, &inf, float64(b.Count()))
+	f.add("_sum", tags, nil, float64(b.Sum()))
+	f.add("_count", tags, nil, float64(b.Count()))
+	if c.openMetrics && !created.IsZero() {
+		f.add("_created", tags, nil, unixSeconds(created))
+	}
+}
+
+// family returns the family with the given name, creating it if needed.  It
+// returns nil if a family of the same name but a different type exists, in
+// which case the samples are dropped.
+func (c *prometheusCollector) family(name, typ, unit string) *prometheusFamily {
+	name = sanitizePrometheusName(name)
+	if c.openMetrics {
+		if prometheusCounter == typ {
+			name = strings.TrimSuffix(name, "_total")
+		}
+		if "" != unit && !strings.HasSuffix(name, "_"+unit) {
+			name += "_" + unit
+		}
+	} else {
+		unit = ""
+	}
+	f, ok := c.families[name]
+	if !ok {
+		f = &prometheusFamily{name: name, typ: typ, unit: unit}
+		c.families[name] = f
+	} else if f.typ != typ {
+		return nil
+	}
+	return f
+}
+
+type prometheusWriter interface {
+	WriteByte(byte) error
+	WriteString(string) (int, error)
+}
+
+func writePrometheusLabels(w prometheusWriter, labels []prometheusLabel) {
+	if 0 == len(labels) {
+		return
+	}
+	w.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(l.name)
+		w.WriteString(`="`)
+		w.WriteString(prometheusLabelValueEscaper.Replace(l.value))
+		w.WriteByte('"')
+	}
+	w.WriteByte('}')
+}
+
+var prometheusLabelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatPrometheusFloat formats a value the way the Prometheus client
+// libraries do.
+func formatPrometheusFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sanitizePrometheusName replaces every character that is not allowed in a
+// Prometheus metric name with an underscore.
+func sanitizePrometheusName(name string) string {
+	return sanitizePrometheus(name, true)
+}
+
+// sanitizePrometheusLabelName replaces every character that is not allowed in
+// a Prometheus label name with an underscore.
+func sanitizePrometheusLabelName(name string) string {
+	return sanitizePrometheus(name, false)
+}
+
+func sanitizePrometheus(name string, colons bool) string {
+	if "" == name {
+		return "_"
+	}
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_':
+		case '0' <= c && c <= '9':
+		case c == ':' && colons:
+		default:
+			b[i] = '_'
+		}
+	}
+	if '0' <= name[0] && name[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("requests.total", r).Inc(47)
+	NewRegisteredGauge("queue-length", r).Update(3)
+	NewRegisteredGaugeFloat64("load", r).Update(0.5)
+	h := NewRegisteredHistogram("latency", r, NewUniformSample(100))
+	for i := 1; i <= 4; i++ {
+		h.Update(int64(i))
+	}
+	mm := NewRegisteredMultiMetric("http", map[string]string{"method": "GET", "path": "/\"a\""}, r)
+	mm.GetOrAdd("hits", NewCounter()).(Counter).Inc(2)
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, r); nil != err {
+		t.Fatal(err)
+	}
+	expected := `# TYPE http_hits counter
+http_hits{method="GET",path="/\"a\""} 2
+# TYPE latency summary
+latency{quantile="0.5"} 2.5
+latency{quantile="0.75"} 3.75
+latency{quantile="0.95"} 4
+latency{quantile="0.99"} 4
+latency{quantile="0.999"} 4
+latency_sum 10
+latency_count 4
+# TYPE load gauge
+load 0.5
+# TYPE queue_length gauge
+queue_length 3
+# TYPE requests_total counter
+requests_total 47
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("WritePrometheus():\n%s\n!=\n%s", expected, s)
+	}
+}
+
+func TestWritePrometheusMultiMetricLabelsSorted(t *testing.T) {
+	r := NewRegistry()
+	GetOrRegisterMultiMetric("http", map[string]string{"code": "500"}, r).
+		GetOrAdd("hits", NewCounter()).(Counter).Inc(1)
+	GetOrRegisterMultiMetric("http2", map[string]string{"code": "200"}, r).
+		GetOrAdd("hits", NewCounter()).(Counter).Inc(2)
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, r); nil != err {
+		t.Fatal(err)
+	}
+	expected := `# TYPE http2_hits counter
+http2_hits{code="200"} 2
+# TYPE http_hits counter
+http_hits{code="500"} 1
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("WritePrometheus():\n%s\n!=\n%s", expected, s)
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(1)
+
+	w := httptest.NewRecorder()
+	PrometheusHandler(r).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); PrometheusContentType != ct {
+		t.Errorf("Content-Type: %s != %s\n", PrometheusContentType, ct)
+	}
+	if body := w.Body.String(); "# TYPE foo counter\nfoo 1\n" != body {
+		t.Errorf("body: %q\n", body)
+	}
+}
+
+func TestSanitizePrometheusName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"foo", "foo"},
+		{"foo.bar-baz", "foo_bar_baz"},
+		{"ns:foo", "ns:foo"},
+		{"1foo", "_1foo"},
+		{"", "_"},
+	}
+	for _, testCase := range testCases {
+		if s := sanitizePrometheusName(testCase.name); testCase.expected != s {
+			t.Errorf("sanitizePrometheusName(%q): %q != %q\n", testCase.name, testCase.expected, s)
+		}
+	}
+	if s := sanitizePrometheusLabelName("ns:foo"); "ns_foo" != s {
+		t.Errorf("sanitizePrometheusLabelName(\"ns:foo\"): \"ns_foo\" != %q\n", s)
+	}
+}
+
+func TestWritePrometheusBucketHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredBucketHistogram("latency", r, []float64{1, 2.5, 5})
+	for _, v := range []int64{1, 2, 3, 4, 10} {
+		h.Update(v)
+	}
+	vec := GetOrRegisterHistogramVec("size", r, func() Sample { return NewBucketSample([]float64{10}) }, "code")
+	vec.WithLabelValues("200").Update(5)
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, r); nil != err {
+		t.Fatal(err)
+	}
+	expected := `# TYPE latency histogram
+latency_bucket{le="1"} 1
+latency_bucket{le="2.5"} 2
+latency_bucket{le="5"} 4
+latency_bucket{le="+Inf"} 5
+latency_sum 20
+latency_count 5
+# TYPE size histogram
+size_bucket{code="200",le="10"} 1
+size_bucket{code="200",le="+Inf"} 1
+size_sum{code="200"} 5
+size_count{code="200"} 1
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("WritePrometheus():\n%s\n!=\n%s", expected, s)
+	}
+}
@@ -2,8 +2,12 @@ package metrics
 
 import (
 	"fmt"
+	"hash/fnv"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // DuplicateMetric is the error returned by Registry.Register when a metric
@@ -43,29 +47,82 @@ type Registry interface {
 	UnregisterAll()
 }
 
+// A TaggedRegistry is a Registry whose metrics are identified by a name and a
+// set of tags, so that metrics with the same name and different tags do not
+// collide.  Metrics registered through the Registry methods have no tags.
+type TaggedRegistry interface {
+	Registry
+
+	// Call the given function for each registered metric with its tags.
+	EachTagged(func(string, map[string]string, Metric))
+
+	// Get the metric by the given name and tags or nil if none is registered.
+	GetTagged(string, map[string]string) Metric
+
+	// Gets an existing metric or registers the given one under the given name
+	// and tags.
+	// The interface can be the metric to register if not found in registry,
+	// or a function returning the metric for lazy instantiation.
+	GetOrRegisterTagged(string, map[string]string, Metric) Metric
+
+	// Register the given metric under the given name and tags.
+	RegisterTagged(string, map[string]string, Metric) error
+
+	// Unregister the metric with the given name and tags.
+	UnregisterTagged(string, map[string]string)
+}
+
 // StandardRegistry is the standard implementation of a Registry is
 // a mutex-protected map of names to metrics.
+//
+// Tagged metrics are kept apart in a map from the hash of their canonical
+// name and sorted tags to the metrics with that hash.
 type StandardRegistry struct {
 	metrics map[string]Metric
+	tagged  map[uint64][]*taggedMetric
 
 	mutex sync.Mutex
 }
 
 // NewRegistry creates a new registry.
 func NewRegistry() Registry {
-	return &StandardRegistry{metrics: make(map[string]Metric)}
+	return &StandardRegistry{
+		metrics: make(map[string]Metric),
+		tagged:  make(map[uint64][]*taggedMetric),
+	}
 }
 
-// Each calls the given function for each registered metric.
+// Each calls the given function for each registered metric.  Tagged metrics
+// are passed by name only, so several of them may share a name.
 func (r *StandardRegistry) Each(fn func(string, Metric)) {
-	for name, m := range r.registered() {
+	r.EachTagged(func(name string, _ map[string]string, m Metric) {
 		fn(name, m)
+	})
+}
+
+// EachTagged calls the given function for each registered metric with its
+// tags, which are nil for metrics registered without tags.
+func (r *StandardRegistry) EachTagged(fn func(string, map[string]string, Metric)) {
+	untagged, tagged := r.registeredTagged()
+	for name, m := range untagged {
+		fn(name, nil, m)
+	}
+	for _, t := range tagged {
+		fn(t.name, t.tags.Map(), t.metric)
 	}
 }
 
-func (r *StandardRegistry) registered() map[string]Metric {
+func (r *StandardRegistry) registeredTagged() (map[string]Metric, []*taggedMetric) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	tagged := make([]*taggedMetric, 0, len(r.tagged))
+	for _, bucket := range r.tagged {
+		tagged = append(tagged, bucket...)
+	}
+	return r.registered(), tagged
+}
+
+func (r *StandardRegistry) registered() map[string]Metric {
 	metrics := make(map[string]Metric, len(r.metrics))
 	for name, m := range r.metrics {
 		metrics[name] = m
@@ -97,6 +154,77 @@ func (r *StandardRegistry) GetOrRegister(name string, m Metric) Metric {
 	return m
 }
 
+// GetTagged gets the metric by the given name and tags or nil if none is
+// registered.
+func (r *StandardRegistry) GetTagged(name string, tags map[string]string) Metric {
+	if 0 == len(tags) {
+		return r.Get(name)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if t := r.findTagged(name, newTagSet(tags)); nil != t {
+		return t.metric
+	}
+	return nil
+}
+
+// GetOrRegisterTagged gets an existing metric or creates and registers a new
+// one under the given name and tags.
+// The interface can be the metric to register if not found in registry,
+// or a function returning the metric for lazy instantiation.
+func (r *StandardRegistry) GetOrRegisterTagged(name string, tags map[string]string, m Metric) Metric {
+	if 0 == len(tags) {
+		return r.GetOrRegister(name, m)
+	}
+	ts := newTagSet(tags)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if t := r.findTagged(name, ts); nil != t {
+		return t.metric
+	}
+	if v := reflect.ValueOf(m); v.Kind() == reflect.Func {
+		m = v.Call(nil)[0].Interface()
+	}
+	r.registerTagged(name, ts, m)
+	return m
+}
+
+// RegisterTagged registers the given metric under the given name and tags.
+// Returns a DuplicateMetric if a metric by the given name and tags is already
+// registered.
+func (r *StandardRegistry) RegisterTagged(name string, tags map[string]string, m Metric) error {
+	if 0 == len(tags) {
+		return r.Register(name, m)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.registerTagged(name, newTagSet(tags), m)
+}
+
+// UnregisterTagged unregisters the metric with the given name and tags.
+func (r *StandardRegistry) UnregisterTagged(name string, tags map[string]string) {
+	if 0 == len(tags) {
+		r.Unregister(name)
+		return
+	}
+	ts := newTagSet(tags)
+	h := ts.hash(name)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	bucket := r.tagged[h]
+	for i, t := range bucket {
+		if t.name == name && t.tags.Equal(ts) {
+			bucket = append(bucket[:i], bucket[i+1:]...)
+			break
+		}
+	}
+	if 0 == len(bucket) {
+		delete(r.tagged, h)
+	} else {
+		r.tagged[h] = bucket
+	}
+}
+
 // Register the given metric under the given name.  Returns a DuplicateMetric
 // if a metric by the given name is already registered.
 func (r *StandardRegistry) Register(name string, m Metric) error {
@@ -112,6 +240,11 @@ func (r *StandardRegistry) Unregister(name string) {
 	delete(r.metrics, name)
 }
 
+// Snapshot takes a point-in-time snapshot of all metrics in the registry.
+func (r *StandardRegistry) Snapshot() *RegistrySnapshot {
+	return SnapshotRegistry(r)
+}
+
 // UnregisterAll unregisters all metrics.  (Mostly for testing.)
 func (r *StandardRegistry) UnregisterAll() {
 	r.mutex.Lock()
@@ -119,6 +252,9 @@ func (r *StandardRegistry) UnregisterAll() {
 	for name := range r.metrics {
 		delete(r.metrics, name)
 	}
+	for h := range r.tagged {
+		delete(r.tagged, h)
+	}
 }
 
 func (r *StandardRegistry) register(name string, m Metric) error {
@@ -129,6 +265,371 @@ func (r *StandardRegistry) register(name string, m Metric) error {
 	return nil
 }
 
+func (r *StandardRegistry) findTagged(name string, tags tagSet) *taggedMetric {
+	for _, t := range r.tagged[tags.hash(name)] {
+		if t.name == name && t.tags.Equal(tags) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (r *StandardRegistry) registerTagged(name string, tags tagSet, m Metric) error {
+	if nil != r.findTagged(name, tags) {
+		return DuplicateMetric(formatTaggedName(name, tags.Map()))
+	}
+	if nil == r.tagged {
+		r.tagged = make(map[uint64][]*taggedMetric)
+	}
+	h := tags.hash(name)
+	r.tagged[h] = append(r.tagged[h], &taggedMetric{name: name, tags: tags, metric: m})
+	return nil
+}
+
+// taggedMetric is a metric registered under a name and a set of tags.
+type taggedMetric struct {
+	name   string
+	tags   tagSet
+	metric Metric
+}
+
+// tag is a single key-value pair of a tagSet.
+type tag struct {
+	key, value string
+}
+
+// tagSet is a canonical, sorted by key, copy of a tag map.
+type tagSet []tag
+
+func newTagSet(tags map[string]string) tagSet {
+	ts := make(tagSet, 0, len(tags))
+	for k, v := range tags {
+		ts = append(ts, tag{k, v})
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i].key < ts[j].key })
+	return ts
+}
+
+// Equal reports whether both tag sets hold the same tags.
+func (ts tagSet) Equal(other tagSet) bool {
+	if len(ts) != len(other) {
+		return false
+	}
+	for i := range ts {
+		if ts[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Map returns the tags as a new map.
+func (ts tagSet) Map() map[string]string {
+	tags := make(map[string]string, len(ts))
+	for _, t := range ts {
+		tags[t.key] = t.value
+	}
+	return tags
+}
+
+// hash returns the FNV-1a hash of the name and the tags.  Separator bytes
+// which cannot occur in valid UTF-8 keep distinct sets from hashing the same
+// input.
+func (ts tagSet) hash(name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	for _, t := range ts {
+		h.Write([]byte{0xff})
+		h.Write([]byte(t.key))
+		h.Write([]byte{0xfe})
+		h.Write([]byte(t.value))
+	}
+	return h.Sum64()
+}
+
+// formatTags formats tags as "{k1=v1,k2=v2}", sorted by key, or returns an
+// empty string if there are no tags.
+func formatTags(tags map[string]string) string {
+	if 0 == len(tags) {
+		return ""
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatTaggedName formats a name and tags as "name{k1=v1,k2=v2}".
+func formatTaggedName(name string, tags map[string]string) string {
+	return name + formatTags(tags)
+}
+
+// registryEntry is a metric of a registry with its name and tags.  The
+// creation time is only set in a RegistrySnapshot, whose metrics no longer
+// know it.
+type registryEntry struct {
+	name    string
+	tags    map[string]string
+	metric  Metric
+	created time.Time
+}
+
+// sortedRegistryEntries returns the metrics of a registry sorted by name and
+// tags.  Tags are only known for a TaggedRegistry.
+func sortedRegistryEntries(r Registry) []registryEntry {
+	entries := make([]registryEntry, 0)
+	if tr, ok := r.(TaggedRegistry); ok {
+		tr.EachTagged(func(name string, tags map[string]string, m Metric) {
+			entries = append(entries, registryEntry{name: name, tags: tags, metric: m})
+		})
+	} else {
+		r.Each(func(name string, m Metric) {
+			entries = append(entries, registryEntry{name: name, metric: m})
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].name != entries[j].name {
+			return entries[i].name < entries[j].name
+		}
+		return formatTags(entries[i].tags) < formatTags(entries[j].tags)
+	})
+	return entries
+}
+
+// PrefixedRegistry is a child registry which namespaces the names of its
+// metrics with a prefix and optionally adds constant tags to them.  Metrics
+// are stored in the parent registry under their fully qualified names.  A
+// child only sees the metrics registered through it or through its nested
+// children, so that siblings whose prefixes share a beginning, like "a" and
+// "ab", stay apart.
+type PrefixedRegistry struct {
+	parent Registry
+	prefix string
+	tags   map[string]string
+	owned  []*prefixedKeys // keys registered through the child, then through each of its ancestors
+}
+
+// prefixedKeys is the set of fully qualified names and tags, formatted by
+// formatTaggedName, of the metrics registered through a child registry.
+type prefixedKeys struct {
+	mutex sync.Mutex
+	keys  map[string]struct{}
+}
+
+func newPrefixedKeys() *prefixedKeys {
+	return &prefixedKeys{keys: make(map[string]struct{})}
+}
+
+// NewPrefixedRegistry creates a child registry of the given parent, or of the
+// DefaultRegistry if parent is nil, which prepends the prefix to the names of
+// its metrics.  Child registries can be nested.
+func NewPrefixedRegistry(prefix string, parent Registry) Registry {
+	return newPrefixedRegistry(prefix, nil, parent)
+}
+
+// NewChildRegistry creates a child registry of the given parent, or of the
+// DefaultRegistry if parent is nil, which prepends the prefix to the names of
+// its metrics and merges the given constant tags into the tags of every metric
+// it contains.  Child registries can be nested.  It panics if the parent is not
+// a TaggedRegistry.
+func NewChildRegistry(prefix string, tags map[string]string, parent Registry) TaggedRegistry {
+	if nil == parent {
+		parent = DefaultRegistry
+	}
+	if _, ok := parent.(TaggedRegistry); !ok {
+		panic(fmt.Sprintf("metrics: parent registry %T does not support tags", parent))
+	}
+	return newPrefixedRegistry(prefix, tags, parent)
+}
+
+func newPrefixedRegistry(prefix string, tags map[string]string, parent Registry) *PrefixedRegistry {
+	if nil == parent {
+		parent = DefaultRegistry
+	}
+	// nested children are flattened onto the root so that names and tags
+	// only need to be qualified once
+	if p, ok := parent.(*PrefixedRegistry); ok {
+		return &PrefixedRegistry{
+			parent: p.parent,
+			prefix: p.prefix + prefix,
+			tags:   mergeTags(p.tags, tags),
+			owned:  append([]*prefixedKeys{newPrefixedKeys()}, p.owned...),
+		}
+	}
+	return &PrefixedRegistry{
+		parent: parent,
+		prefix: prefix,
+		tags:   mergeTags(nil, tags),
+		owned:  []*prefixedKeys{newPrefixedKeys()},
+	}
+}
+
+// Each calls the given function for each metric of the child registry with
+// its fully qualified name.
+func (r *PrefixedRegistry) Each(fn func(string, Metric)) {
+	r.EachTagged(func(name string, _ map[string]string, m Metric) {
+		fn(name, m)
+	})
+}
+
+// EachTagged calls the given function for each metric of the child registry
+// with its fully qualified name and all of its tags.
+func (r *PrefixedRegistry) EachTagged(fn func(string, map[string]string, Metric)) {
+	own := r.owned[0]
+	each := func(name string, tags map[string]string, m Metric) {
+		if !strings.HasPrefix(name, r.prefix) {
+			return
+		}
+		own.mutex.Lock()
+		_, ok := own.keys[formatTaggedName(name, tags)]
+		own.mutex.Unlock()
+		if ok {
+			fn(name, tags, m)
+		}
+	}
+	if tr, ok := r.parent.(TaggedRegistry); ok {
+		tr.EachTagged(each)
+		return
+	}
+	r.parent.Each(func(name string, m Metric) {
+		each(name, nil, m)
+	})
+}
+
+// Get the metric by the given name or nil if none is registered.
+func (r *PrefixedRegistry) Get(name string) Metric {
+	return r.GetTagged(name, nil)
+}
+
+// GetOrRegister gets an existing metric or creates and registers a new one.
+// Threadsafe alternative to calling Get and Register on failure.
+// The interface can be the metric to register if not found in registry,
+// or a function returning the metric for lazy instantiation.
+func (r *PrefixedRegistry) GetOrRegister(name string, m Metric) Metric {
+	return r.GetOrRegisterTagged(name, nil, m)
+}
+
+// Register the given metric under the given name.  Returns a DuplicateMetric
+// if a metric by the given name is already registered.
+func (r *PrefixedRegistry) Register(name string, m Metric) error {
+	return r.RegisterTagged(name, nil, m)
+}
+
+// Unregister the metric with the given name.
+func (r *PrefixedRegistry) Unregister(name string) {
+	r.UnregisterTagged(name, nil)
+}
+
+// Snapshot takes a point-in-time snapshot of all metrics of the child
+// registry.
+func (r *PrefixedRegistry) Snapshot() *RegistrySnapshot {
+	return SnapshotRegistry(r)
+}
+
+// UnregisterAll unregisters all metrics of the child registry.  Metrics of
+// the parent outside of the namespace of the child are kept.
+func (r *PrefixedRegistry) UnregisterAll() {
+	type entry struct {
+		name string
+		tags map[string]string
+	}
+	entries := make([]entry, 0)
+	r.EachTagged(func(name string, tags map[string]string, _ Metric) {
+		entries = append(entries, entry{name, tags})
+	})
+	for _, e := range entries {
+		r.disown(e.name, e.tags)
+		if tr, ok := r.parent.(TaggedRegistry); ok {
+			tr.UnregisterTagged(e.name, e.tags)
+		} else {
+			r.parent.Unregister(e.name)
+		}
+	}
+}
+
+// GetTagged gets the metric by the given name and tags or nil if none is
+// registered.
+func (r *PrefixedRegistry) GetTagged(name string, tags map[string]string) Metric {
+	tags = mergeTags(r.tags, tags)
+	if 0 == len(tags) {
+		return r.parent.Get(r.prefix + name)
+	}
+	return r.taggedParent().GetTagged(r.prefix+name, tags)
+}
+
+// GetOrRegisterTagged gets an existing metric or creates and registers a new
+// one under the given name and tags.
+// The interface can be the metric to register if not found in registry,
+// or a function returning the metric for lazy instantiation.
+func (r *PrefixedRegistry) GetOrRegisterTagged(name string, tags map[string]string, m Metric) Metric {
+	tags = mergeTags(r.tags, tags)
+	r.own(r.prefix+name, tags)
+	if 0 == len(tags) {
+		return r.parent.GetOrRegister(r.prefix+name, m)
+	}
+	return r.taggedParent().GetOrRegisterTagged(r.prefix+name, tags, m)
+}
+
+// RegisterTagged registers the given metric under the given name and tags.
+// Returns a DuplicateMetric if a metric by the given name and tags is already
+// registered.
+func (r *PrefixedRegistry) RegisterTagged(name string, tags map[string]string, m Metric) error {
+	tags = mergeTags(r.tags, tags)
+	var err error
+	if 0 == len(tags) {
+		err = r.parent.Register(r.prefix+name, m)
+	} else {
+		err = r.taggedParent().RegisterTagged(r.prefix+name, tags, m)
+	}
+	if nil == err {
+		r.own(r.prefix+name, tags)
+	}
+	return err
+}
+
+// UnregisterTagged unregisters the metric with the given name and tags.
+func (r *PrefixedRegistry) UnregisterTagged(name string, tags map[string]string) {
+	tags = mergeTags(r.tags, tags)
+	r.disown(r.prefix+name, tags)
+	if 0 == len(tags) {
+		r.parent.Unregister(r.prefix + name)
+		return
+	}
+	r.taggedParent().UnregisterTagged(r.prefix+name, tags)
+}
+
+// own records a metric as registered through the child and its ancestors.
+func (r *PrefixedRegistry) own(name string, tags map[string]string) {
+	key := formatTaggedName(name, tags)
+	for _, o := range r.owned {
+		o.mutex.Lock()
+		o.keys[key] = struct{}{}
+		o.mutex.Unlock()
+	}
+}
+
+// disown forgets a metric registered through the child and its ancestors.
+func (r *PrefixedRegistry) disown(name string, tags map[string]string) {
+	key := formatTaggedName(name, tags)
+	for _, o := range r.owned {
+		o.mutex.Lock()
+		delete(o.keys, key)
+		o.mutex.Unlock()
+	}
+}
+
+// taggedParent returns the parent as a TaggedRegistry.  It panics if the
+// parent does not support tags.
+func (r *PrefixedRegistry) taggedParent() TaggedRegistry {
+	tr, ok := r.parent.(TaggedRegistry)
+	if !ok {
+		panic(fmt.Sprintf("metrics: parent registry %T does not support tags", r.parent))
+	}
+	return tr
+}
+
 var DefaultRegistry Registry = NewRegistry()
 
 // Each calls the given function for each registered metric.
@@ -147,6 +648,89 @@ func GetOrRegister(name string, m Metric) Metric {
 	return DefaultRegistry.GetOrRegister(name, m)
 }
 
+// MetricTypeMismatch is the error returned by GetOrRegisterAs when a metric of
+// another type is already registered under the name.
+type MetricTypeMismatch struct {
+	Name     string // Name of the metric
+	Existing Metric // Metric already registered under the name
+	Want     string // Name of the requested type
+}
+
+func (err *MetricTypeMismatch) Error() string {
+	return fmt.Sprintf("metric %s is a %T, not a %s", err.Name, err.Existing, err.Want)
+}
+
+// GetOrRegisterAs returns the existing metric of type T registered under the
+// given name, or registers and returns a new one constructed by fn.  Returns
+// a MetricTypeMismatch if a metric of another type is already registered.
+// Unlike GetOrRegister it calls fn directly instead of through reflection.
+func GetOrRegisterAs[T Metric](r Registry, name string, fn func() T) (T, error) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return getOrRegisterAs(name, fn, r.Get, r.Register)
+}
+
+// GetOrRegisterTaggedAs is like GetOrRegisterAs for metrics identified by a
+// name and tags.  It panics if r is nil and the DefaultRegistry is not a
+// TaggedRegistry.
+func GetOrRegisterTaggedAs[T Metric](r TaggedRegistry, name string, tags map[string]string, fn func() T) (T, error) {
+	if nil == r {
+		r = DefaultRegistry.(TaggedRegistry)
+	}
+	return getOrRegisterAs(name, fn, func(name string) Metric {
+		return r.GetTagged(name, tags)
+	}, func(name string, m Metric) error {
+		return r.RegisterTagged(name, tags, m)
+	})
+}
+
+// getOrRegisterAs gets or registers a metric, retrying a lost race once.  A
+// registry which keeps reporting a duplicate it does not return is
+// inconsistent, so its DuplicateMetric is returned rather than retried.
+func getOrRegisterAs[T Metric](name string, fn func() T, get func(string) Metric, register func(string, Metric) error) (T, error) {
+	var (
+		created T
+		ok      bool
+	)
+	for retried := false; ; retried = true {
+		if m := get(name); nil != m {
+			if ok {
+				// lost a race against another registration
+				if s, isStoppable := Metric(created).(interface{ Stop() }); isStoppable {
+					s.Stop()
+				}
+			}
+			if metric, isT := m.(T); isT {
+				return metric, nil
+			}
+			var zero T
+			return zero, &MetricTypeMismatch{
+				Name:     name,
+				Existing: m,
+				Want:     strings.TrimPrefix(fmt.Sprintf("%T", (*T)(nil)), "*"),
+			}
+		}
+		if !ok {
+			created, ok = fn(), true
+		}
+		err := register(name, created)
+		if _, isDuplicate := err.(DuplicateMetric); !isDuplicate || retried {
+			return created, err
+		}
+	}
+}
+
+// mustGetOrRegisterAs is GetOrRegisterAs for the GetOrRegister* constructors,
+// which panic with a MetricTypeMismatch instead of returning it.
+func mustGetOrRegisterAs[T Metric](r Registry, name string, fn func() T) T {
+	m, err := GetOrRegisterAs(r, name, fn)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
 // Register the given metric under the given name.  Returns a DuplicateMetric
 // if a metric by the given name is already registered.
 func Register(name string, m Metric) error {
@@ -10,3 +10,13 @@ func BenchmarkRegistry(b *testing.B) {
 		r.Each(func(string, Metric) {})
 	}
 }
+
+func BenchmarkRegistryGetOrRegisterTagged(b *testing.B) {
+	r := NewRegistry().(TaggedRegistry)
+	tags := map[string]string{"method": "GET", "code": "200", "path": "/"}
+	r.RegisterTagged("foo", tags, NewCounter())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetOrRegisterTagged("foo", tags, NewCounter)
+	}
+}
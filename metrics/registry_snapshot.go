@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// RegistrySnapshot is an immutable, point-in-time copy of the metrics of a
+// Registry.  Every metric is copied with SnapshotMetric at the time the
+// snapshot is taken, so reading a snapshot always yields the same values.
+// Taking a snapshot does not modify the registry.
+type RegistrySnapshot struct {
+	time    time.Time
+	entries []registryEntry
+}
+
+// SnapshotRegistry takes a snapshot of the metrics in the given registry, or
+// in the DefaultRegistry if r is nil.
+func SnapshotRegistry(r Registry) *RegistrySnapshot {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	s := &RegistrySnapshot{time: time.Now()}
+	s.entries = sortedRegistryEntries(r)
+	for i, e := range s.entries {
+		if cm, ok := e.metric.(createdMetric); ok {
+			s.entries[i].created = cm.Created()
+		}
+		s.entries[i].metric = SnapshotMetric(e.metric)
+	}
+	return s
+}
+
+// Time returns the time the snapshot was taken.
+func (s *RegistrySnapshot) Time() time.Time { return s.time }
+
+// Len returns the number of metrics in the snapshot.
+func (s *RegistrySnapshot) Len() int { return len(s.entries) }
+
+// Names returns the sorted names of the metrics in the snapshot.  Metrics with
+// the same name and different tags share a single name.
+func (s *RegistrySnapshot) Names() []string {
+	names := make([]string, 0, len(s.entries))
+	for _, e := range s.entries {
+		if 0 == len(names) || names[len(names)-1] != e.name {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// Each calls the given function for each metric in the snapshot with its
+// tags, in lexicographical order of names and tags.
+func (s *RegistrySnapshot) Each(fn func(string, map[string]string, Metric)) {
+	for _, e := range s.entries {
+		fn(e.name, mergeTags(e.tags, nil), e.metric)
+	}
+}
+
+// Get returns the metric by the given name without tags or nil if there is
+// none.
+func (s *RegistrySnapshot) Get(name string) Metric {
+	return s.GetTagged(name, nil)
+}
+
+// GetTagged returns the metric by the given name and tags or nil if there is
+// none.
+func (s *RegistrySnapshot) GetTagged(name string, tags map[string]string) Metric {
+	key := formatTags(tags)
+	i := sort.Search(len(s.entries), func(i int) bool {
+		e := s.entries[i]
+		if e.name != name {
+			return e.name > name
+		}
+		return formatTags(e.tags) >= key
+	})
+	if i < len(s.entries) && s.entries[i].name == name && formatTags(s.entries[i].tags) == key {
+		return s.entries[i].metric
+	}
+	return nil
+}
+
+// Counter returns the Counter by the given name or nil if there is none.
+func (s *RegistrySnapshot) Counter(name string) Counter {
+	m, _ := s.Get(name).(Counter)
+	return m
+}
+
+// Gauge returns the Gauge by the given name or nil if there is none.
+func (s *RegistrySnapshot) Gauge(name string) Gauge {
+	m, _ := s.Get(name).(Gauge)
+	return m
+}
+
+// GaugeFloat64 returns the GaugeFloat64 by the given name or nil if there is
+// none.
+func (s *RegistrySnapshot) GaugeFloat64(name string) GaugeFloat64 {
+	m, _ := s.Get(name).(GaugeFloat64)
+	return m
+}
+
+// Histogram returns the Histogram by the given name or nil if there is none.
+func (s *RegistrySnapshot) Histogram(name string) Histogram {
+	m, _ := s.Get(name).(Histogram)
+	return m
+}
+
+// Meter returns the Meter by the given name or nil if there is none.
+func (s *RegistrySnapshot) Meter(name string) Meter {
+	m, _ := s.Get(name).(Meter)
+	return m
+}
+
+// Timer returns the Timer by the given name or nil if there is none.
+func (s *RegistrySnapshot) Timer(name string) Timer {
+	m, _ := s.Get(name).(Timer)
+	return m
+}
+
+// MultiMetric returns the MultiMetric by the given name or nil if there is
+// none.
+func (s *RegistrySnapshot) MultiMetric(name string) MultiMetric {
+	m, _ := s.Get(name).(MultiMetric)
+	return m
+}
+
+// RegistrySnapshotDiff is the difference between two snapshots of a registry.
+// Metrics are keyed by "name{k1=v1,k2=v2}", or by name for metrics without
+// tags.
+type RegistrySnapshotDiff struct {
+	Interval time.Duration    // Time between the snapshots
+	Added    []string         // Sorted keys of metrics only in the later snapshot
+	Removed  []string         // Sorted keys of metrics only in the earlier snapshot
+	Deltas   map[string]int64 // Change of the count of Counters, Histograms, Meters and Timers in both
+}
+
+// Diff returns the difference from an earlier snapshot to s.
+func (s *RegistrySnapshot) Diff(prev *RegistrySnapshot) *RegistrySnapshotDiff {
+	d := &RegistrySnapshotDiff{
+		Interval: s.time.Sub(prev.time),
+		Added:    make([]string, 0),
+		Removed:  make([]string, 0),
+		Deltas:   make(map[string]int64),
+	}
+	before := make(map[string]Metric, len(prev.entries))
+	for _, e := range prev.entries {
+		before[formatTaggedName(e.name, e.tags)] = e.metric
+	}
+	for _, e := range s.entries {
+		key := formatTaggedName(e.name, e.tags)
+		m, ok := before[key]
+		if !ok {
+			d.Added = append(d.Added, key)
+			continue
+		}
+		delete(before, key)
+		if count, ok := snapshotCount(e.metric); ok {
+			if prevCount, ok := snapshotCount(m); ok {
+				d.Deltas[key] = count - prevCount
+			}
+		}
+	}
+	for key := range before {
+		d.Removed = append(d.Removed, key)
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	return d
+}
+
+// snapshotCount returns the count of metrics which have one.
+func snapshotCount(m Metric) (int64, bool) {
+	switch metric := m.(type) {
+	case Counter:
+		return metric.Count(), true
+	case Histogram:
+		return metric.Count(), true
+	case Meter:
+		return metric.Count(), true
+	case Timer:
+		return metric.Count(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistrySnapshot(t *testing.T) {
+	r := NewRegistry()
+	c := GetOrRegisterCounter("counter", r)
+	c.Inc(47)
+	GetOrRegisterGauge("gauge", r).Update(47)
+	h := GetOrRegisterHistogram("histogram", r, NewUniformSample(100))
+	h.Update(47)
+	r.(TaggedRegistry).RegisterTagged("counter", map[string]string{"host": "a"}, NewCounter())
+
+	s := SnapshotRegistry(r)
+	c.Inc(1)
+	h.Update(1)
+
+	if count := s.Counter("counter").Count(); 47 != count {
+		t.Errorf("s.Counter(\"counter\").Count(): 47 != %v\n", count)
+	}
+	if value := s.Gauge("gauge").Value(); 47 != value {
+		t.Errorf("s.Gauge(\"gauge\").Value(): 47 != %v\n", value)
+	}
+	if count := s.Histogram("histogram").Count(); 1 != count {
+		t.Errorf("s.Histogram(\"histogram\").Count(): 1 != %v\n", count)
+	}
+	if m := s.Timer("counter"); nil != m {
+		t.Errorf("s.Timer(\"counter\"): nil != %v\n", m)
+	}
+	if m := s.GetTagged("counter", map[string]string{"host": "a"}); nil == m {
+		t.Error("tagged metric missing from the snapshot")
+	}
+	if l := s.Len(); 4 != l {
+		t.Errorf("s.Len(): 4 != %v\n", l)
+	}
+	names := s.Names()
+	if 3 != len(names) || "counter" != names[0] || "gauge" != names[1] || "histogram" != names[2] {
+		t.Errorf("s.Names(): [counter gauge histogram] != %v\n", names)
+	}
+	if s.Time().IsZero() || s.Time().After(time.Now()) {
+		t.Errorf("s.Time(): %v\n", s.Time())
+	}
+}
+
+// plainRegistry implements only the methods of the Registry interface, like
+// registries outside of this package.
+type plainRegistry struct {
+	Registry
+}
+
+func TestRegistrySnapshotPlainRegistry(t *testing.T) {
+	r := plainRegistry{NewRegistry()}
+	GetOrRegisterCounter("counter", r).Inc(47)
+	if count := SnapshotRegistry(r).Counter("counter").Count(); 47 != count {
+		t.Errorf("counter.Count(): 47 != %v\n", count)
+	}
+	if count := r.Registry.(*StandardRegistry).Snapshot().Counter("counter").Count(); 47 != count {
+		t.Errorf("Snapshot() counter.Count(): 47 != %v\n", count)
+	}
+}
+
+func TestRegistrySnapshotSnapshotter(t *testing.T) {
+	r := NewRegistry()
+	m := &customMetric{value: 47}
+	r.Register("custom", m)
+
+	s := SnapshotRegistry(r)
+	m.value = 74
+	if v := s.Get("custom").(*customMetric).value; 47 != v {
+		t.Errorf("custom.value: 47 != %v\n", v)
+	}
+}
+
+func TestRegistrySnapshotMetricVec(t *testing.T) {
+	r := NewRegistry()
+	v := NewRegisteredCounterVec("requests", r, "code")
+	v.WithLabelValues("200").Inc(1)
+
+	s := SnapshotRegistry(r)
+	v.WithLabelValues("200").Inc(5)
+	v.WithLabelValues("500").Inc(1)
+	n := 0
+	s.Get("requests").(MetricVec).Each(func(labels map[string]string, m Metric) {
+		n++
+		if count := m.(Counter).Count(); 1 != count {
+			t.Errorf("requests{code=%s}: 1 != %v\n", labels["code"], count)
+		}
+	})
+	if 1 != n {
+		t.Errorf("children: 1 != %v\n", n)
+	}
+}
+
+func TestRegistrySnapshotDiff(t *testing.T) {
+	r := NewRegistry()
+	c := GetOrRegisterCounter("counter", r)
+	c.Inc(5)
+	GetOrRegisterGauge("removed", r)
+	prev := SnapshotRegistry(r)
+
+	c.Inc(42)
+	r.Unregister("removed")
+	r.(TaggedRegistry).RegisterTagged("added", map[string]string{"host": "a"}, NewCounter())
+	d := SnapshotRegistry(r).Diff(prev)
+
+	if delta := d.Deltas["counter"]; 42 != delta {
+		t.Errorf("d.Deltas[\"counter\"]: 42 != %v\n", delta)
+	}
+	if 1 != len(d.Added) || "added{host=a}" != d.Added[0] {
+		t.Errorf("d.Added: [added{host=a}] != %v\n", d.Added)
+	}
+	if 1 != len(d.Removed) || "removed" != d.Removed[0] {
+		t.Errorf("d.Removed: [removed] != %v\n", d.Removed)
+	}
+	if d.Interval < 0 {
+		t.Errorf("d.Interval: %v\n", d.Interval)
+	}
+}
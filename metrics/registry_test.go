@@ -1,10 +1,14 @@
 package metrics
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 // Check the interfaces are satisfied
 func TestRegistry_impl(t *testing.T) {
 	var _ Registry = new(StandardRegistry)
+	var _ TaggedRegistry = new(StandardRegistry)
 }
 
 func TestRegistry(t *testing.T) {
@@ -137,3 +141,312 @@ func TestRegistryUnregister(t *testing.T) {
 		t.Errorf("metrics: %d != %d\n", 0, l)
 	}
 }
+
+func TestTaggedRegistry(t *testing.T) {
+	r := NewRegistry().(TaggedRegistry)
+	if err := r.RegisterTagged("foo", map[string]string{"a": "1"}, NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+	if err := r.RegisterTagged("foo", map[string]string{"a": "2"}, NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+	if err := r.Register("foo", NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+	if err := r.RegisterTagged("foo", map[string]string{"a": "1"}, NewGauge()); nil == err {
+		t.Fatal(err)
+	} else if "duplicate metric: foo{a=1}" != err.Error() {
+		t.Fatal(err)
+	}
+
+	r.GetTagged("foo", map[string]string{"a": "2"}).(Counter).Inc(47)
+	if count := r.GetTagged("foo", map[string]string{"a": "2"}).(Counter).Count(); 47 != count {
+		t.Fatal(count)
+	}
+	if m := r.GetTagged("foo", map[string]string{"a": "3"}); nil != m {
+		t.Fatal(m)
+	}
+
+	tagged := 0
+	r.EachTagged(func(name string, tags map[string]string, m Metric) {
+		if "foo" != name {
+			t.Fatal(name)
+		}
+		if 0 != len(tags) {
+			tagged++
+		}
+	})
+	if 2 != tagged {
+		t.Fatal(tagged)
+	}
+	i := 0
+	r.Each(func(string, Metric) { i++ })
+	if 3 != i {
+		t.Fatal(i)
+	}
+
+	r.UnregisterTagged("foo", map[string]string{"a": "1"})
+	if m := r.GetTagged("foo", map[string]string{"a": "1"}); nil != m {
+		t.Fatal(m)
+	}
+	r.UnregisterAll()
+	i = 0
+	r.Each(func(string, Metric) { i++ })
+	if 0 != i {
+		t.Fatal(i)
+	}
+}
+
+func TestTaggedRegistryGetOrRegisterTagged(t *testing.T) {
+	r := NewRegistry().(TaggedRegistry)
+	tags := map[string]string{"b": "2", "a": "1"}
+
+	// First metric wins with GetOrRegisterTagged
+	_ = r.GetOrRegisterTagged("foo", tags, NewCounter)
+	m := r.GetOrRegisterTagged("foo", map[string]string{"a": "1", "b": "2"}, NewGauge)
+	if _, ok := m.(Counter); !ok {
+		t.Fatal(m)
+	}
+
+	// tags are copied on registration
+	tags["c"] = "3"
+	r.EachTagged(func(name string, tags map[string]string, m Metric) {
+		if 2 != len(tags) {
+			t.Fatal(tags)
+		}
+	})
+}
+
+func TestWritePrometheusTagged(t *testing.T) {
+	r := NewRegistry().(TaggedRegistry)
+	r.GetOrRegisterTagged("requests", map[string]string{"code": "500"}, NewCounter).(Counter).Inc(1)
+	r.GetOrRegisterTagged("requests", map[string]string{"code": "200"}, NewCounter).(Counter).Inc(2)
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, r); nil != err {
+		t.Fatal(err)
+	}
+	expected := `# TYPE requests counter
+requests{code="200"} 2
+requests{code="500"} 1
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("WritePrometheus():\n%s\n!=\n%s", expected, s)
+	}
+}
+
+func TestPrefixedRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register("other", NewGauge())
+	pr := NewPrefixedRegistry("prefix.", r)
+
+	pr.Register("foo", NewCounter())
+	if m := r.Get("prefix.foo"); nil == m {
+		t.Fatal("metric not registered in parent under the qualified name")
+	}
+	if m := pr.Get("foo"); nil == m {
+		t.Fatal("metric not found in child")
+	}
+	GetOrRegisterCounter("foo", pr).Inc(47)
+	if count := r.Get("prefix.foo").(Counter).Count(); 47 != count {
+		t.Fatal(count)
+	}
+
+	i := 0
+	pr.Each(func(name string, m Metric) {
+		i++
+		if "prefix.foo" != name {
+			t.Fatal(name)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+
+	pr.UnregisterAll()
+	if m := r.Get("other"); nil == m {
+		t.Fatal("metric outside of the child namespace was unregistered")
+	}
+	if m := r.Get("prefix.foo"); nil != m {
+		t.Fatal(m)
+	}
+}
+
+func TestPrefixedRegistryNested(t *testing.T) {
+	r := NewRegistry()
+	child := NewPrefixedRegistry("a.", r)
+	grandchild := NewPrefixedRegistry("b.", child)
+
+	grandchild.Register("foo", NewCounter())
+	if m := r.Get("a.b.foo"); nil == m {
+		t.Fatal("metric not registered under the qualified name")
+	}
+	for _, reg := range []Registry{child, grandchild} {
+		i := 0
+		reg.Each(func(name string, m Metric) {
+			i++
+			if "a.b.foo" != name {
+				t.Fatal(name)
+			}
+		})
+		if 1 != i {
+			t.Fatal(i)
+		}
+	}
+	grandchild.Unregister("foo")
+	if m := r.Get("a.b.foo"); nil != m {
+		t.Fatal(m)
+	}
+}
+
+func TestPrefixedRegistrySiblings(t *testing.T) {
+	r := NewRegistry()
+	a := NewPrefixedRegistry("a", r)
+	ab := NewPrefixedRegistry("ab", r)
+	a.Register(".x", NewCounter())
+	ab.Register(".x", NewCounter())
+
+	i := 0
+	a.Each(func(name string, m Metric) {
+		i++
+		if "a.x" != name {
+			t.Fatal(name)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+
+	a.UnregisterAll()
+	if m := r.Get("a.x"); nil != m {
+		t.Fatal(m)
+	}
+	if m := r.Get("ab.x"); nil == m {
+		t.Fatal("metric of a sibling was unregistered")
+	}
+}
+
+func TestChildRegistryTags(t *testing.T) {
+	r := NewRegistry().(TaggedRegistry)
+	child := NewChildRegistry("db.", map[string]string{"service": "users"}, r)
+	other := NewChildRegistry("db.", map[string]string{"service": "orders"}, r)
+
+	GetOrRegisterCounter("queries", child).Inc(1)
+	GetOrRegisterCounter("queries", other).Inc(2)
+	child.GetOrRegisterTagged("errors", map[string]string{"code": "42"}, NewCounter)
+
+	if m := r.GetTagged("db.queries", map[string]string{"service": "users"}); 1 != m.(Counter).Count() {
+		t.Fatal(m)
+	}
+	if m := r.GetTagged("db.errors", map[string]string{"service": "users", "code": "42"}); nil == m {
+		t.Fatal("tags not merged")
+	}
+
+	i := 0
+	child.EachTagged(func(name string, tags map[string]string, m Metric) {
+		i++
+		if "users" != tags["service"] {
+			t.Fatal(tags)
+		}
+	})
+	if 2 != i {
+		t.Fatal(i)
+	}
+
+	nested := NewPrefixedRegistry("replica.", child).(TaggedRegistry)
+	nested.Register("lag", NewGauge())
+	if m := r.GetTagged("db.replica.lag", map[string]string{"service": "users"}); nil == m {
+		t.Fatal("nested child lost the constant tags")
+	}
+}
+
+func TestGetOrRegisterAs(t *testing.T) {
+	r := NewRegistry()
+	c, err := GetOrRegisterAs(r, "foo", NewCounter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Inc(47)
+	if c, err = GetOrRegisterAs(r, "foo", NewCounter); err != nil {
+		t.Fatal(err)
+	}
+	if count := c.Count(); 47 != count {
+		t.Errorf("c.Count(): 47 != %v\n", count)
+	}
+}
+
+func TestGetOrRegisterAsTypeMismatch(t *testing.T) {
+	r := NewRegistry()
+	r.Register("foo", NewGauge())
+	called := false
+	_, err := GetOrRegisterAs(r, "foo", func() Counter {
+		called = true
+		return NewCounter()
+	})
+	mismatch, ok := err.(*MetricTypeMismatch)
+	if !ok {
+		t.Fatal(err)
+	}
+	if called {
+		t.Fatal("factory called for a registered name")
+	}
+	if _, ok := mismatch.Existing.(Gauge); !ok {
+		t.Fatal(mismatch.Existing)
+	}
+	if s := err.Error(); "metric foo is a *metrics.StandardGauge, not a metrics.Counter" != s {
+		t.Fatal(s)
+	}
+}
+
+// inconsistentRegistry reports every name as a duplicate without returning a
+// metric for it.
+type inconsistentRegistry struct {
+	Registry
+}
+
+func (inconsistentRegistry) Get(string) Metric { return nil }
+
+func (inconsistentRegistry) Register(name string, _ Metric) error {
+	return DuplicateMetric(name)
+}
+
+func TestGetOrRegisterAsInconsistentRegistry(t *testing.T) {
+	if _, err := GetOrRegisterAs(inconsistentRegistry{NewRegistry()}, "foo", NewCounter); nil == err {
+		t.Fatal(err)
+	}
+}
+
+func TestGetOrRegisterTaggedAs(t *testing.T) {
+	r := NewRegistry().(TaggedRegistry)
+	tags := map[string]string{"host": "a"}
+	v, err := GetOrRegisterTaggedAs(r, "foo", tags, func() *CounterVec { return NewCounterVec("code") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := r.GetTagged("foo", tags); m != v {
+		t.Fatal(m)
+	}
+	if _, err := GetOrRegisterTaggedAs(r, "foo", tags, NewGauge); nil == err {
+		t.Fatal(err)
+	}
+
+	defer func(r Registry) { DefaultRegistry = r }(DefaultRegistry)
+	DefaultRegistry = NewRegistry()
+	if _, err := GetOrRegisterTaggedAs(nil, "foo", tags, NewCounter); err != nil {
+		t.Fatal(err)
+	}
+	if m := DefaultRegistry.(TaggedRegistry).GetTagged("foo", tags); nil == m {
+		t.Fatal("metric not registered in the DefaultRegistry")
+	}
+}
+
+func TestGetOrRegisterCounterTypeMismatch(t *testing.T) {
+	r := NewRegistry()
+	r.Register("foo", NewGauge())
+	defer func() {
+		if _, ok := recover().(*MetricTypeMismatch); !ok {
+			t.Fatal("expected a MetricTypeMismatch panic")
+		}
+	}()
+	GetOrRegisterCounter("foo", r)
+}
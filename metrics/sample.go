@@ -1,12 +1,19 @@
 package metrics
 
 import (
+	"fmt"
 	"math"
+	"math/bits"
 	"math/rand"
+	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+const rescaleThreshold = time.Hour
+
 // Sample maintains a statistically-significant selection of values from
 // a stream.
 type Sample interface {
@@ -26,6 +33,12 @@ type Sample interface {
 	Variance() float64
 }
 
+// sampleResetter is implemented by samples which can take a read-only copy of
+// themselves and clear themselves atomically.
+type sampleResetter interface {
+	SnapshotAndReset() Sample
+}
+
 // NilSample is a no-op Sample.
 type NilSample struct{}
 
@@ -133,6 +146,189 @@ func (s *SampleSnapshot) Values() []int64 {
 	return values
 }
 
+// ExpDecaySample is an exponentially-decaying sample using a forward-decaying
+// priority reservoir.  See Cormode et al's "Forward Decay: A Practical Time
+// Decay Model for Streaming Systems".
+//
+// <http://dimacs.rutgers.edu/~graham/pubs/papers/fwddecay.pdf>
+type ExpDecaySample struct {
+	alpha         float64
+	count         int64
+	mutex         sync.Mutex
+	reservoirSize int
+	t0, t1        time.Time
+	values        *expDecaySampleHeap
+}
+
+// NewExpDecaySample constructs a new exponentially-decaying sample with the
+// given reservoir size and alpha.
+func NewExpDecaySample(reservoirSize int, alpha float64) Sample {
+	if UseNilMetrics {
+		return NilSample{}
+	}
+	s := &ExpDecaySample{
+		alpha:         alpha,
+		reservoirSize: reservoirSize,
+		t0:            time.Now(),
+		values:        newExpDecaySampleHeap(reservoirSize),
+	}
+	s.t1 = s.t0.Add(rescaleThreshold)
+	return s
+}
+
+// Clear clears all samples.
+func (s *ExpDecaySample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count = 0
+	s.t0 = time.Now()
+	s.t1 = s.t0.Add(rescaleThreshold)
+	s.values.Clear()
+}
+
+// Count returns the number of samples recorded, which may exceed the
+// reservoir size.
+func (s *ExpDecaySample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+// Max returns the maximum value in the sample, which may not be the maximum
+// value ever to be part of the sample.
+func (s *ExpDecaySample) Max() int64 {
+	return SampleMax(s.Values())
+}
+
+// Mean returns the mean of the values in the sample.
+func (s *ExpDecaySample) Mean() float64 {
+	return SampleMean(s.Values())
+}
+
+// Min returns the minimum value in the sample, which may not be the minimum
+// value ever to be part of the sample.
+func (s *ExpDecaySample) Min() int64 {
+	return SampleMin(s.Values())
+}
+
+// Percentile returns an arbitrary percentile of values in the sample.
+func (s *ExpDecaySample) Percentile(p float64) float64 {
+	return SamplePercentile(s.Values(), p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values in the
+// sample.
+func (s *ExpDecaySample) Percentiles(ps []float64) []float64 {
+	return SamplePercentiles(s.Values(), ps)
+}
+
+// Size returns the size of the sample, which is at most the reservoir size.
+func (s *ExpDecaySample) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.values.Size()
+}
+
+// Snapshot returns a read-only copy of the sample.
+func (s *ExpDecaySample) Snapshot() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	vals := s.values.Values()
+	values := make([]int64, len(vals))
+	for i, v := range vals {
+		values[i] = v.v
+	}
+	return &SampleSnapshot{
+		count:  s.count,
+		values: values,
+	}
+}
+
+// SnapshotAndReset returns a read-only copy of the sample and clears it in a
+// single critical section, so that no update is lost.
+func (s *ExpDecaySample) SnapshotAndReset() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	vals := s.values.Values()
+	values := make([]int64, len(vals))
+	for i, v := range vals {
+		values[i] = v.v
+	}
+	snapshot := &SampleSnapshot{
+		count:  s.count,
+		values: values,
+	}
+	s.count = 0
+	s.t0 = time.Now()
+	s.t1 = s.t0.Add(rescaleThreshold)
+	s.values.Clear()
+	return snapshot
+}
+
+// StdDev returns the standard deviation of the values in the sample.
+func (s *ExpDecaySample) StdDev() float64 {
+	return SampleStdDev(s.Values())
+}
+
+// Sum returns the sum of the values in the sample.
+func (s *ExpDecaySample) Sum() int64 {
+	return SampleSum(s.Values())
+}
+
+// Update samples a new value.
+func (s *ExpDecaySample) Update(v int64) {
+	s.update(time.Now(), v)
+}
+
+// Values returns a copy of the values in the sample.
+func (s *ExpDecaySample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	vals := s.values.Values()
+	values := make([]int64, len(vals))
+	for i, v := range vals {
+		values[i] = v.v
+	}
+	return values
+}
+
+// Variance returns the variance of the values in the sample.
+func (s *ExpDecaySample) Variance() float64 {
+	return SampleVariance(s.Values())
+}
+
+// update samples a new value at a particular timestamp.  This is a method all
+// its own to facilitate testing.
+func (s *ExpDecaySample) update(t time.Time, v int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count++
+	// Rescale before computing the priority, which would overflow after a
+	// long enough time without updates otherwise.
+	if t.After(s.t1) {
+		values := s.values.Values()
+		t0 := s.t0
+		s.values.Clear()
+		s.t0 = t
+		s.t1 = s.t0.Add(rescaleThreshold)
+		for _, v := range values {
+			v.k = v.k * math.Exp(-s.alpha*s.t0.Sub(t0).Seconds())
+			s.values.Push(v)
+		}
+	}
+	// 1-rand.Float64() is in (0, 1] so the priority is always finite.
+	sample := expDecaySample{
+		k: math.Exp(t.Sub(s.t0).Seconds()*s.alpha) / (1 - rand.Float64()),
+		v: v,
+	}
+	if s.values.Size() < s.reservoirSize {
+		s.values.Push(sample)
+	} else if s.reservoirSize > 0 && s.values.Min().k < sample.k {
+		s.values.Pop()
+		s.values.Push(sample)
+	}
+}
+
 // A UniformSample using Vitter's Algorithm R.
 //
 // <http://www.cs.umd.edu/~samir/498/vitter.pdf>
@@ -228,6 +424,20 @@ func (s *UniformSample) Snapshot() Sample {
 	}
 }
 
+// SnapshotAndReset returns a read-only copy of the sample and clears it in a
+// single critical section, so that no update is lost.
+func (s *UniformSample) SnapshotAndReset() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := &SampleSnapshot{
+		count:  s.count,
+		values: s.values,
+	}
+	s.count = 0
+	s.values = make([]int64, 0, s.reservoirSize)
+	return snapshot
+}
+
 // StdDev returns the standard deviation of the values in the sample.
 func (s *UniformSample) StdDev() float64 {
 	s.mutex.Lock()
@@ -373,3 +583,922 @@ type int64Slice []int64
 func (p int64Slice) Len() int           { return len(p) }
 func (p int64Slice) Less(i, j int) bool { return p[i] < p[j] }
 func (p int64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// expDecaySample represents an individual sample in a heap.
+type expDecaySample struct {
+	k float64
+	v int64
+}
+
+func newExpDecaySampleHeap(reservoirSize int) *expDecaySampleHeap {
+	return &expDecaySampleHeap{make([]expDecaySample, 0, reservoirSize)}
+}
+
+// expDecaySampleHeap is a min-heap of expDecaySamples.
+// The internal implementation is copied from the standard library's
+// container/heap.
+type expDecaySampleHeap struct {
+	s []expDecaySample
+}
+
+func (h *expDecaySampleHeap) Clear() {
+	h.s = h.s[:0]
+}
+
+func (h *expDecaySampleHeap) Min() expDecaySample {
+	return h.s[0]
+}
+
+func (h *expDecaySampleHeap) Push(s expDecaySample) {
+	h.s = append(h.s, s)
+	h.up(len(h.s) - 1)
+}
+
+func (h *expDecaySampleHeap) Pop() expDecaySample {
+	n := len(h.s) - 1
+	h.s[0], h.s[n] = h.s[n], h.s[0]
+	h.down(0, n)
+	s := h.s[n]
+	h.s = h.s[0:n]
+	return s
+}
+
+func (h *expDecaySampleHeap) Size() int {
+	return len(h.s)
+}
+
+func (h *expDecaySampleHeap) Values() []expDecaySample {
+	values := make([]expDecaySample, len(h.s))
+	copy(values, h.s)
+	return values
+}
+
+func (h *expDecaySampleHeap) up(j int) {
+	for {
+		i := (j - 1) / 2 // parent
+		if i == j || !(h.s[j].k < h.s[i].k) {
+			break
+		}
+		h.s[i], h.s[j] = h.s[j], h.s[i]
+		j = i
+	}
+}
+
+func (h *expDecaySampleHeap) down(i, n int) {
+	for {
+		j1 := 2*i + 1
+		if j1 >= n || j1 < 0 { // j1 < 0 after int overflow
+			break
+		}
+		j := j1 // left child
+		if j2 := j1 + 1; j2 < n && !(h.s[j1].k < h.s[j2].k) {
+			j = j2 // = 2*i + 2  // right child
+		}
+		if !(h.s[j].k < h.s[i].k) {
+			break
+		}
+		h.s[i], h.s[j] = h.s[j], h.s[i]
+		i = j
+	}
+}
+
+// HdrSample is a Sample which records every value into log-linear buckets,
+// in the manner of Gil Tene's HdrHistogram, instead of keeping a reservoir of
+// some of them.  Values between the lowest and the highest trackable value
+// are recorded with the configured number of significant decimal digits, in
+// memory that only depends on the range and the precision.  Count, Sum, Min,
+// Max, Mean, StdDev and Variance are exact over all recorded values.
+//
+// Negative values are recorded in the lowest bucket and values above the
+// highest trackable value in the highest bucket.
+//
+// <https://hdrhistogram.github.io/HdrHistogram/>
+type HdrSample struct {
+	hdrCounts
+	mutex sync.Mutex
+}
+
+// NewHdrSample constructs a new HdrSample tracking values from lowest to
+// highest, which must be at least 1 and twice lowest, with the given number of
+// significant digits from 1 to 5.  It panics if the arguments are out of
+// range.
+func NewHdrSample(lowest, highest int64, significantDigits int) Sample {
+	layout := newHdrLayout(lowest, highest, significantDigits)
+	if UseNilMetrics {
+		return NilSample{}
+	}
+	return &HdrSample{hdrCounts: newHdrCounts(layout)}
+}
+
+// Clear clears all samples.
+func (s *HdrSample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hdrCounts = newHdrCounts(s.layout)
+}
+
+// Count returns the number of samples recorded.
+func (s *HdrSample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+// Max returns the maximum value recorded.
+func (s *HdrSample) Max() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.max
+}
+
+// Mean returns the mean of the values recorded.
+func (s *HdrSample) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.mean()
+}
+
+// Min returns the minimum value recorded.
+func (s *HdrSample) Min() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.min
+}
+
+// Percentile returns an arbitrary percentile of values recorded, accurate to
+// the configured number of significant digits.
+func (s *HdrSample) Percentile(p float64) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values recorded,
+// accurate to the configured number of significant digits.
+func (s *HdrSample) Percentiles(ps []float64) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.percentiles(ps)
+}
+
+// Size returns the number of buckets holding values.
+func (s *HdrSample) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.values())
+}
+
+// Snapshot returns a read-only copy of the sample.
+func (s *HdrSample) Snapshot() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &HdrSampleSnapshot{s.copy()}
+}
+
+// SnapshotAndReset returns a read-only copy of the sample and clears it in a
+// single critical section, so that no update is lost.
+func (s *HdrSample) SnapshotAndReset() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := &HdrSampleSnapshot{s.hdrCounts}
+	s.hdrCounts = newHdrCounts(s.layout)
+	return snapshot
+}
+
+// StdDev returns the standard deviation of the values recorded.
+func (s *HdrSample) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Sum returns the sum of the values recorded.
+func (s *HdrSample) Sum() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sum
+}
+
+// Update records a new value.
+func (s *HdrSample) Update(v int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.update(v)
+}
+
+// Values returns one value for every bucket holding values, which is the
+// highest value the bucket stands for.
+func (s *HdrSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.values()
+}
+
+// Variance returns the variance of the values recorded.
+func (s *HdrSample) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.variance()
+}
+
+// HdrSampleSnapshot is a read-only copy of an HdrSample.  Snapshots of
+// samples with the same range and precision can be merged.
+type HdrSampleSnapshot struct {
+	hdrCounts
+}
+
+// Clear panics.
+func (*HdrSampleSnapshot) Clear() {
+	panic("Clear called on a HdrSampleSnapshot")
+}
+
+// Count returns the count of values at the time the snapshot was taken.
+func (s *HdrSampleSnapshot) Count() int64 { return s.count }
+
+// Max returns the maximal value at the time the snapshot was taken.
+func (s *HdrSampleSnapshot) Max() int64 { return s.max }
+
+// Mean returns the mean value at the time the snapshot was taken.
+func (s *HdrSampleSnapshot) Mean() float64 { return s.mean() }
+
+// Merge returns a snapshot holding the values of both snapshots.  It returns
+// an error if the snapshots differ in range or precision.
+func (s *HdrSampleSnapshot) Merge(other *HdrSampleSnapshot) (*HdrSampleSnapshot, error) {
+	if *s.layout != *other.layout {
+		return nil, fmt.Errorf(
+			"cannot merge HdrSample snapshots of range %d-%d with %d digits and range %d-%d with %d digits",
+			s.layout.lowest, s.layout.highest, s.layout.significantDigits,
+			other.layout.lowest, other.layout.highest, other.layout.significantDigits)
+	}
+	merged := &HdrSampleSnapshot{s.copy()}
+	merged.merge(&other.hdrCounts)
+	return merged, nil
+}
+
+// Min returns the minimal value at the time the snapshot was taken.
+func (s *HdrSampleSnapshot) Min() int64 { return s.min }
+
+// Percentile returns an arbitrary percentile of values at the time the
+// snapshot was taken.
+func (s *HdrSampleSnapshot) Percentile(p float64) float64 {
+	return s.percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values at the time
+// the snapshot was taken.
+func (s *HdrSampleSnapshot) Percentiles(ps []float64) []float64 {
+	return s.percentiles(ps)
+}
+
+// Size returns the number of buckets holding values at the time the snapshot
+// was taken.
+func (s *HdrSampleSnapshot) Size() int { return len(s.values()) }
+
+// Snapshot returns the snapshot.
+func (s *HdrSampleSnapshot) Snapshot() Sample { return s }
+
+// StdDev returns the standard deviation of values at the time the snapshot was
+// taken.
+func (s *HdrSampleSnapshot) StdDev() float64 { return math.Sqrt(s.variance()) }
+
+// Sum returns the sum of values at the time the snapshot was taken.
+func (s *HdrSampleSnapshot) Sum() int64 { return s.sum }
+
+// Update panics.
+func (*HdrSampleSnapshot) Update(int64) {
+	panic("Update called on a HdrSampleSnapshot")
+}
+
+// Values returns one value for every bucket holding values at the time the
+// snapshot was taken, which is the highest value the bucket stands for.
+func (s *HdrSampleSnapshot) Values() []int64 { return s.values() }
+
+// Variance returns the variance of values at the time the snapshot was taken.
+func (s *HdrSampleSnapshot) Variance() float64 { return s.variance() }
+
+// hdrLayout maps values to buckets.  Values are split into buckets by powers
+// of two, and every bucket into sub-buckets of equal width, enough of them to
+// keep the configured number of significant digits.  The lower half of every
+// bucket but the first is covered by the previous one, so only the upper half
+// of their sub-buckets is counted.
+type hdrLayout struct {
+	lowest, highest             int64
+	significantDigits           int
+	unitMagnitude               uint
+	subBucketHalfCountMagnitude uint
+	subBucketHalfCount          int
+	subBucketMask               int64
+	countsLen                   int
+}
+
+func newHdrLayout(lowest, highest int64, significantDigits int) *hdrLayout {
+	if lowest < 1 {
+		panic("metrics: HdrSample lowest trackable value must be at least 1")
+	}
+	if highest < 2*lowest {
+		panic("metrics: HdrSample highest trackable value must be at least twice the lowest")
+	}
+	if significantDigits < 1 || significantDigits > 5 {
+		panic("metrics: HdrSample significant digits must be from 1 to 5")
+	}
+	largestSingleUnit := 2 * int64(math.Pow10(significantDigits))
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(float64(largestSingleUnit))))
+	l := &hdrLayout{
+		lowest:                      lowest,
+		highest:                     highest,
+		significantDigits:           significantDigits,
+		unitMagnitude:               uint(bits.Len64(uint64(lowest)) - 1),
+		subBucketHalfCountMagnitude: subBucketCountMagnitude - 1,
+	}
+	subBucketCount := int64(1) << subBucketCountMagnitude
+	l.subBucketHalfCount = int(subBucketCount / 2)
+	l.subBucketMask = (subBucketCount - 1) << l.unitMagnitude
+
+	bucketCount := 1
+	for smallestUntrackable := subBucketCount << l.unitMagnitude; smallestUntrackable <= highest; smallestUntrackable <<= 1 {
+		bucketCount++
+		if smallestUntrackable > math.MaxInt64/2 {
+			break
+		}
+	}
+	l.countsLen = (bucketCount + 1) * l.subBucketHalfCount
+	return l
+}
+
+// index returns the index of the counter of the given value.
+func (l *hdrLayout) index(v int64) int {
+	if v < 0 {
+		v = 0
+	} else if v > l.highest {
+		v = l.highest
+	}
+	bucket := bits.Len64(uint64(v|l.subBucketMask)) - int(l.unitMagnitude) - int(l.subBucketHalfCountMagnitude+1)
+	subBucket := int(v >> (uint(bucket) + l.unitMagnitude))
+	return (bucket+1)<<l.subBucketHalfCountMagnitude + subBucket - l.subBucketHalfCount
+}
+
+// highestEquivalent returns the highest value counted by the given counter.
+func (l *hdrLayout) highestEquivalent(i int) int64 {
+	bucket := i>>l.subBucketHalfCountMagnitude - 1
+	subBucket := i&(l.subBucketHalfCount-1) + l.subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= l.subBucketHalfCount
+		bucket = 0
+	}
+	shift := uint(bucket) + l.unitMagnitude
+	return int64(subBucket)<<shift + int64(1)<<shift - 1
+}
+
+// hdrCounts are the counters of an HdrSample together with the exact
+// statistics of the values recorded.  The variance is kept with Welford's
+// algorithm as the sum of squared differences from the mean.
+type hdrCounts struct {
+	layout   *hdrLayout
+	counts   []int64
+	count    int64
+	sum      int64
+	min, max int64
+	m2       float64
+}
+
+func newHdrCounts(layout *hdrLayout) hdrCounts {
+	return hdrCounts{
+		layout: layout,
+		counts: make([]int64, layout.countsLen),
+	}
+}
+
+func (c *hdrCounts) copy() hdrCounts {
+	counts := make([]int64, len(c.counts))
+	copy(counts, c.counts)
+	cp := *c
+	cp.counts = counts
+	return cp
+}
+
+func (c *hdrCounts) mean() float64 {
+	if 0 == c.count {
+		return 0.0
+	}
+	return float64(c.sum) / float64(c.count)
+}
+
+// merge adds the values of other, which must have the same layout.
+func (c *hdrCounts) merge(other *hdrCounts) {
+	if 0 == other.count {
+		return
+	}
+	if 0 == c.count || other.min < c.min {
+		c.min = other.min
+	}
+	if 0 == c.count || other.max > c.max {
+		c.max = other.max
+	}
+	// Chan et al's formula for combining the sums of squared differences
+	d := other.mean() - c.mean()
+	n := float64(c.count) * float64(other.count) / float64(c.count+other.count)
+	c.m2 += other.m2 + d*d*n
+	c.count += other.count
+	c.sum += other.sum
+	for i, n := range other.counts {
+		c.counts[i] += n
+	}
+}
+
+// percentile returns the highest value of the bucket holding the given
+// percentile, limited to the exact minimum and maximum, which are returned
+// for the 0th and 100th percentile.
+func (c *hdrCounts) percentile(p float64) float64 {
+	if 0 == c.count {
+		return 0.0
+	}
+	rank := int64(math.Ceil(p * float64(c.count)))
+	if rank < 1 {
+		return float64(c.min)
+	} else if rank >= c.count {
+		return float64(c.max)
+	}
+	var total int64
+	for i, n := range c.counts {
+		total += n
+		if total >= rank {
+			v := c.layout.highestEquivalent(i)
+			if v < c.min {
+				v = c.min
+			} else if v > c.max {
+				v = c.max
+			}
+			return float64(v)
+		}
+	}
+	return float64(c.max)
+}
+
+func (c *hdrCounts) percentiles(ps []float64) []float64 {
+	scores := make([]float64, len(ps))
+	for i, p := range ps {
+		scores[i] = c.percentile(p)
+	}
+	return scores
+}
+
+func (c *hdrCounts) update(v int64) {
+	if 0 == c.count || v < c.min {
+		c.min = v
+	}
+	if 0 == c.count || v > c.max {
+		c.max = v
+	}
+	d := float64(v) - c.mean()
+	c.count++
+	c.sum += v
+	c.m2 += d * (float64(v) - c.mean())
+	c.counts[c.layout.index(v)]++
+}
+
+func (c *hdrCounts) values() []int64 {
+	values := make([]int64, 0)
+	for i, n := range c.counts {
+		if 0 != n {
+			values = append(values, c.layout.highestEquivalent(i))
+		}
+	}
+	return values
+}
+
+func (c *hdrCounts) variance() float64 {
+	if 0 == c.count {
+		return 0.0
+	}
+	return c.m2 / float64(c.count)
+}
+
+// BucketSample is a Sample which counts values in buckets with fixed upper
+// bounds, as Prometheus histograms do.  Unlike a reservoir, the buckets of
+// samples from different processes can be added up.
+type BucketSample interface {
+	Sample
+
+	// Buckets returns the upper bounds of the buckets and the cumulative
+	// counts of values less than or equal to each bound.  The count of
+	// values less than +Inf is Count.
+	Buckets() ([]float64, []int64)
+}
+
+// LinearBuckets returns count upper bounds, the first being start and each
+// following one width higher.  It panics if count is less than 1.
+func LinearBuckets(start, width float64, count int) []float64 {
+	if count < 1 {
+		panic("metrics: LinearBuckets needs a positive count")
+	}
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start + float64(i)*width
+	}
+	return bounds
+}
+
+// ExponentialBuckets returns count upper bounds, the first being start and
+// each following one factor times the previous one.  It panics if count is
+// less than 1, start is not positive or factor is not greater than 1.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	if count < 1 {
+		panic("metrics: ExponentialBuckets needs a positive count")
+	}
+	if start <= 0 {
+		panic("metrics: ExponentialBuckets needs a positive start")
+	}
+	if factor <= 1 {
+		panic("metrics: ExponentialBuckets needs a factor greater than 1")
+	}
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start
+		start *= factor
+	}
+	return bounds
+}
+
+// StandardBucketSample is the standard implementation of a BucketSample and
+// uses the sync/atomic package to count every value in its bucket.  Count,
+// Sum, Min and Max are exact; percentiles are interpolated linearly within
+// the bucket holding them.
+//
+// Values are recorded in one of two sets of counters, the hot one.  Snapshots
+// swap the sets and wait for the updates of the now cold set to complete, so
+// that they are consistent across all counters without blocking updates.
+// Variance is computed from the squared differences to the first value
+// recorded, which keeps it accurate for large values close to each other.
+type StandardBucketSample struct {
+	countAndHotIdx uint64 // updates started, and the hot set in the high bit
+	bounds         []float64
+	hot            [2]*bucketCounts
+	shift          int64
+	shiftOnce      sync.Once
+	mutex          sync.Mutex // serializes snapshots
+}
+
+// NewBucketSample constructs a new StandardBucketSample with the given upper
+// bounds, to which an implicit +Inf bound is added.  It panics if the bounds
+// are not sorted in increasing order.
+func NewBucketSample(bounds []float64) Sample {
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			panic("metrics: bucket bounds must be sorted in increasing order")
+		}
+	}
+	if UseNilMetrics {
+		return NilSample{}
+	}
+	if n := len(bounds); n > 0 && math.IsInf(bounds[n-1], 1) {
+		bounds = bounds[:n-1]
+	}
+	s := &StandardBucketSample{bounds: make([]float64, len(bounds))}
+	copy(s.bounds, bounds)
+	for i := range s.hot {
+		s.hot[i] = newBucketCounts(len(s.bounds))
+	}
+	return s
+}
+
+// Buckets returns the upper bounds of the buckets and the cumulative counts
+// of values less than or equal to each bound.
+func (s *StandardBucketSample) Buckets() ([]float64, []int64) {
+	return s.snapshot(false).Buckets()
+}
+
+// Clear clears all samples.
+func (s *StandardBucketSample) Clear() {
+	s.snapshot(true)
+}
+
+// Count returns the number of samples recorded.
+func (s *StandardBucketSample) Count() int64 {
+	return int64(atomic.LoadUint64(&s.countAndHotIdx) & (1<<63 - 1))
+}
+
+// Max returns the maximum value recorded.
+func (s *StandardBucketSample) Max() int64 {
+	return s.snapshot(false).Max()
+}
+
+// Mean returns the mean of the values recorded.
+func (s *StandardBucketSample) Mean() float64 {
+	return s.snapshot(false).Mean()
+}
+
+// Min returns the minimum value recorded.
+func (s *StandardBucketSample) Min() int64 {
+	return s.snapshot(false).Min()
+}
+
+// Percentile returns an arbitrary percentile of values recorded, interpolated
+// within the bucket holding it.
+func (s *StandardBucketSample) Percentile(p float64) float64 {
+	return s.snapshot(false).Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values recorded,
+// interpolated within the buckets holding them.
+func (s *StandardBucketSample) Percentiles(ps []float64) []float64 {
+	return s.snapshot(false).Percentiles(ps)
+}
+
+// Size returns the number of buckets holding values.
+func (s *StandardBucketSample) Size() int {
+	return s.snapshot(false).Size()
+}
+
+// Snapshot returns a read-only copy of the sample.
+func (s *StandardBucketSample) Snapshot() Sample {
+	return s.snapshot(false)
+}
+
+// SnapshotAndReset returns a read-only copy of the sample and clears it, so
+// that every update is counted in exactly one snapshot with all of its
+// statistics.
+func (s *StandardBucketSample) SnapshotAndReset() Sample {
+	return s.snapshot(true)
+}
+
+// StdDev returns the standard deviation of the values recorded.
+func (s *StandardBucketSample) StdDev() float64 {
+	return s.snapshot(false).StdDev()
+}
+
+// Sum returns the sum of the values recorded.
+func (s *StandardBucketSample) Sum() int64 {
+	return s.snapshot(false).Sum()
+}
+
+// Update records a new value.
+func (s *StandardBucketSample) Update(v int64) {
+	s.shiftOnce.Do(func() { s.shift = v })
+	n := atomic.AddUint64(&s.countAndHotIdx, 1)
+	s.hot[n>>63].update(sort.SearchFloat64s(s.bounds, float64(v)), v, s.shift)
+}
+
+// Values returns one value for every bucket holding values, which is its
+// upper bound, or the maximum value for the +Inf bucket.
+func (s *StandardBucketSample) Values() []int64 {
+	return s.snapshot(false).Values()
+}
+
+// Variance returns the variance of the values recorded.
+func (s *StandardBucketSample) Variance() float64 {
+	return s.snapshot(false).Variance()
+}
+
+// snapshot swaps the hot and cold counters, waits for the updates of the cold
+// ones to complete and copies them.  Unless reset is set, the cold counters
+// are then added to the hot ones, which always hold all values recorded.
+func (s *StandardBucketSample) snapshot(reset bool) *BucketSampleSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := atomic.AddUint64(&s.countAndHotIdx, 1<<63)
+	count := n & (1<<63 - 1)
+	hot, cold := s.hot[n>>63], s.hot[^n>>63]
+	for count != atomic.LoadUint64(&cold.count) {
+		runtime.Gosched()
+	}
+	snapshot := &BucketSampleSnapshot{bounds: s.bounds, bucketState: cold.state(&s.shift)}
+	if reset {
+		atomic.AddUint64(&s.countAndHotIdx, -count)
+	} else {
+		hot.add(cold)
+	}
+	cold.reset()
+	return snapshot
+}
+
+// bucketCounts is one of the two sets of counters of a StandardBucketSample.
+type bucketCounts struct {
+	count      uint64 // updates completed
+	sum        int64
+	sumSquares uint64 // float64 bits, of the differences to the shift
+	min, max   int64
+	counts     []int64 // per bucket, the last one for values above all bounds
+}
+
+func newBucketCounts(bounds int) *bucketCounts {
+	c := &bucketCounts{counts: make([]int64, bounds+1)}
+	c.reset()
+	return c
+}
+
+// add adds the counters of o, which are not updated concurrently.
+func (c *bucketCounts) add(o *bucketCounts) {
+	for i := range o.counts {
+		if 0 != o.counts[i] {
+			atomic.AddInt64(&c.counts[i], o.counts[i])
+		}
+	}
+	atomic.AddInt64(&c.sum, o.sum)
+	addFloat64(&c.sumSquares, math.Float64frombits(o.sumSquares))
+	c.mark(o.min)
+	c.mark(o.max)
+	atomic.AddUint64(&c.count, o.count)
+}
+
+// mark lowers the lowest or raises the highest value to v if needed.
+func (c *bucketCounts) mark(v int64) {
+	for {
+		min := atomic.LoadInt64(&c.min)
+		if v >= min || atomic.CompareAndSwapInt64(&c.min, min, v) {
+			break
+		}
+	}
+	for {
+		max := atomic.LoadInt64(&c.max)
+		if v <= max || atomic.CompareAndSwapInt64(&c.max, max, v) {
+			break
+		}
+	}
+}
+
+// reset zeroes the counters, which are not updated concurrently.
+func (c *bucketCounts) reset() {
+	for i := range c.counts {
+		atomic.StoreInt64(&c.counts[i], 0)
+	}
+	atomic.StoreInt64(&c.sum, 0)
+	atomic.StoreUint64(&c.sumSquares, 0)
+	atomic.StoreInt64(&c.min, math.MaxInt64)
+	atomic.StoreInt64(&c.max, math.MinInt64)
+	atomic.StoreUint64(&c.count, 0)
+}
+
+// state copies the counters, which are not updated concurrently, computing
+// the variance from the squared differences to the shift.  The shift is only
+// read once a value was counted, after which it is no longer written.
+func (c *bucketCounts) state(shift *int64) bucketState {
+	b := bucketState{counts: make([]int64, len(c.counts))}
+	for i := range c.counts {
+		b.counts[i] = atomic.LoadInt64(&c.counts[i])
+	}
+	b.count = int64(atomic.LoadUint64(&c.count))
+	if 0 == b.count {
+		return b
+	}
+	b.sum = atomic.LoadInt64(&c.sum)
+	b.min = atomic.LoadInt64(&c.min)
+	b.max = atomic.LoadInt64(&c.max)
+	d := float64(b.sum - b.count*(*shift))
+	b.m2 = math.Max(0, math.Float64frombits(atomic.LoadUint64(&c.sumSquares))-d*d/float64(b.count))
+	return b
+}
+
+// update records the value v in bucket i.  The count is incremented last, so
+// that the update is complete once it is visible.
+func (c *bucketCounts) update(i int, v, shift int64) {
+	atomic.AddInt64(&c.counts[i], 1)
+	atomic.AddInt64(&c.sum, v)
+	d := float64(v - shift)
+	addFloat64(&c.sumSquares, d*d)
+	c.mark(v)
+	atomic.AddUint64(&c.count, 1)
+}
+
+// addFloat64 atomically adds delta to the float64 whose bits are at addr.
+func addFloat64(addr *uint64, delta float64) {
+	for {
+		old := atomic.LoadUint64(addr)
+		if atomic.CompareAndSwapUint64(addr, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// bucketState holds the counts and statistics of a BucketSample.
+type bucketState struct {
+	counts     []int64 // per bucket, the last one for values above all bounds
+	count, sum int64
+	min, max   int64
+	m2         float64 // sum of squared differences from the mean
+}
+
+func (b *bucketState) mean() float64 {
+	if 0 == b.count {
+		return 0.0
+	}
+	return float64(b.sum) / float64(b.count)
+}
+
+// BucketSampleSnapshot is a read-only copy of a BucketSample.
+type BucketSampleSnapshot struct {
+	bounds []float64
+	bucketState
+}
+
+// Buckets returns the upper bounds of the buckets and the cumulative counts
+// of values less than or equal to each bound at the time the snapshot was
+// taken.
+func (s *BucketSampleSnapshot) Buckets() ([]float64, []int64) {
+	bounds := make([]float64, len(s.bounds))
+	copy(bounds, s.bounds)
+	cumulative := make([]int64, len(s.bounds))
+	var count int64
+	for i := range s.bounds {
+		count += s.counts[i]
+		cumulative[i] = count
+	}
+	return bounds, cumulative
+}
+
+// Clear panics.
+func (*BucketSampleSnapshot) Clear() {
+	panic("Clear called on a BucketSampleSnapshot")
+}
+
+// Count returns the count of values at the time the snapshot was taken.
+func (s *BucketSampleSnapshot) Count() int64 { return s.count }
+
+// Max returns the maximal value at the time the snapshot was taken.
+func (s *BucketSampleSnapshot) Max() int64 { return s.max }
+
+// Mean returns the mean value at the time the snapshot was taken.
+func (s *BucketSampleSnapshot) Mean() float64 { return s.mean() }
+
+// Min returns the minimal value at the time the snapshot was taken.
+func (s *BucketSampleSnapshot) Min() int64 { return s.min }
+
+// Percentile returns an arbitrary percentile of values at the time the
+// snapshot was taken, interpolated linearly within the bucket holding it.
+// The lowest bucket is taken to start at the minimum and the +Inf bucket to
+// end at the maximum value.
+func (s *BucketSampleSnapshot) Percentile(p float64) float64 {
+	if 0 == s.count {
+		return 0.0
+	}
+	rank := p * float64(s.count)
+	var total int64
+	for i, n := range s.counts {
+		if 0 == n || float64(total+n) < rank {
+			total += n
+			continue
+		}
+		lower, upper := float64(s.min), float64(s.max)
+		if i > 0 && s.bounds[i-1] > lower {
+			lower = s.bounds[i-1]
+		}
+		if i < len(s.bounds) && s.bounds[i] < upper {
+			upper = s.bounds[i]
+		}
+		v := lower + (upper-lower)*(rank-float64(total))/float64(n)
+		return math.Max(float64(s.min), math.Min(float64(s.max), v))
+	}
+	return float64(s.max)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values at the time
+// the snapshot was taken.
+func (s *BucketSampleSnapshot) Percentiles(ps []float64) []float64 {
+	scores := make([]float64, len(ps))
+	for i, p := range ps {
+		scores[i] = s.Percentile(p)
+	}
+	return scores
+}
+
+// Size returns the number of buckets holding values at the time the snapshot
+// was taken.
+func (s *BucketSampleSnapshot) Size() int { return len(s.Values()) }
+
+// Snapshot returns the snapshot.
+func (s *BucketSampleSnapshot) Snapshot() Sample { return s }
+
+// StdDev returns the standard deviation of values at the time the snapshot was
+// taken.
+func (s *BucketSampleSnapshot) StdDev() float64 { return math.Sqrt(s.Variance()) }
+
+// Sum returns the sum of values at the time the snapshot was taken.
+func (s *BucketSampleSnapshot) Sum() int64 { return s.sum }
+
+// Update panics.
+func (*BucketSampleSnapshot) Update(int64) {
+	panic("Update called on a BucketSampleSnapshot")
+}
+
+// Values returns one value for every bucket holding values at the time the
+// snapshot was taken, which is its upper bound, or the maximum value for the
+// +Inf bucket.
+func (s *BucketSampleSnapshot) Values() []int64 {
+	values := make([]int64, 0)
+	for i, n := range s.counts {
+		if 0 == n {
+			continue
+		}
+		if i < len(s.bounds) {
+			values = append(values, int64(s.bounds[i]))
+		} else {
+			values = append(values, s.max)
+		}
+	}
+	return values
+}
+
+// Variance returns the variance of values at the time the snapshot was taken.
+func (s *BucketSampleSnapshot) Variance() float64 {
+	if 0 == s.count {
+		return 0.0
+	}
+	return s.m2 / float64(s.count)
+}
@@ -55,6 +55,26 @@ func BenchmarkCopy1000000(b *testing.B) {
 	}
 }
 
+func BenchmarkExpDecaySample257(b *testing.B) {
+	benchmarkSample(b, NewExpDecaySample(257, 0.015))
+}
+
+func BenchmarkExpDecaySample514(b *testing.B) {
+	benchmarkSample(b, NewExpDecaySample(514, 0.015))
+}
+
+func BenchmarkExpDecaySample1028(b *testing.B) {
+	benchmarkSample(b, NewExpDecaySample(1028, 0.015))
+}
+
+func BenchmarkHdrSample2(b *testing.B) {
+	benchmarkSample(b, NewHdrSample(1, 3600000000000, 2))
+}
+
+func BenchmarkHdrSample3(b *testing.B) {
+	benchmarkSample(b, NewHdrSample(1, 3600000000000, 3))
+}
+
 func BenchmarkUniformSample257(b *testing.B) {
 	benchmarkSample(b, NewUniformSample(257))
 }
@@ -80,3 +100,17 @@ func benchmarkSample(b *testing.B, s Sample) {
 	runtime.ReadMemStats(&memStats)
 	b.Logf("GC cost: %d ns/op", int(memStats.PauseTotalNs-pauseTotalNs)/b.N)
 }
+
+func BenchmarkBucketSample(b *testing.B) {
+	benchmarkSample(b, NewBucketSample(ExponentialBuckets(1, 2, 20)))
+}
+
+func BenchmarkBucketSampleParallel(b *testing.B) {
+	s := NewBucketSample(ExponentialBuckets(1, 2, 20))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Update(1)
+		}
+	})
+}
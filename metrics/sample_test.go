@@ -1,7 +1,9 @@
 package metrics
 
 import (
+	"math"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 )
@@ -10,7 +12,167 @@ import (
 func TestSample_impl(t *testing.T) {
 	var _ Sample = new(NilSample)
 	var _ Sample = new(SampleSnapshot)
+	var _ Sample = new(ExpDecaySample)
 	var _ Sample = new(UniformSample)
+	var _ Sample = new(HdrSample)
+	var _ Sample = new(HdrSampleSnapshot)
+	var _ BucketSample = new(StandardBucketSample)
+	var _ BucketSample = new(BucketSampleSnapshot)
+}
+
+func TestExpDecaySample10(t *testing.T) {
+	rand.Seed(1)
+	s := NewExpDecaySample(100, 0.99)
+	for i := 0; i < 10; i++ {
+		s.Update(int64(i))
+	}
+	if size := s.Count(); 10 != size {
+		t.Errorf("s.Count(): 10 != %v\n", size)
+	}
+	if size := s.Size(); 10 != size {
+		t.Errorf("s.Size(): 10 != %v\n", size)
+	}
+	if l := len(s.Values()); 10 != l {
+		t.Errorf("len(s.Values()): 10 != %v\n", l)
+	}
+	for _, v := range s.Values() {
+		if v > 10 || v < 0 {
+			t.Errorf("out of range [0, 10): %v\n", v)
+		}
+	}
+}
+
+func TestExpDecaySample1000(t *testing.T) {
+	rand.Seed(1)
+	s := NewExpDecaySample(100, 0.99)
+	for i := 0; i < 1000; i++ {
+		s.Update(int64(i))
+	}
+	if size := s.Count(); 1000 != size {
+		t.Errorf("s.Count(): 1000 != %v\n", size)
+	}
+	if size := s.Size(); 100 != size {
+		t.Errorf("s.Size(): 100 != %v\n", size)
+	}
+	if l := len(s.Values()); 100 != l {
+		t.Errorf("len(s.Values()): 100 != %v\n", l)
+	}
+	for _, v := range s.Values() {
+		if v > 1000 || v < 0 {
+			t.Errorf("out of range [0, 1000): %v\n", v)
+		}
+	}
+}
+
+// TestExpDecaySampleRecentDominates checks that, once the reservoir is full,
+// values recorded later in time displace older ones.
+func TestExpDecaySampleRecentDominates(t *testing.T) {
+	rand.Seed(1)
+	s := NewExpDecaySample(100, 0.015).(*ExpDecaySample)
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		s.update(now, 1)
+	}
+	later := now.Add(10 * time.Minute)
+	for i := 0; i < 1000; i++ {
+		s.update(later, 1000)
+	}
+	if mean := s.Mean(); 990 > mean {
+		t.Errorf("s.Mean(): 990 > %v\n", mean)
+	}
+	if p := s.Percentile(0.1); 1000 != p {
+		t.Errorf("10th percentile: 1000 != %v\n", p)
+	}
+}
+
+// This test makes sure that the sample's priority is not amplified by using
+// nanosecond duration since start rather than second duration since start.
+// The priority becomes +Inf quickly after starting if this is done,
+// effectively freezing the set of samples until a rescale step happens.
+func TestExpDecaySampleNanosecondRegression(t *testing.T) {
+	rand.Seed(1)
+	s := NewExpDecaySample(100, 0.99)
+	for i := 0; i < 100; i++ {
+		s.Update(10)
+	}
+	time.Sleep(1 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		s.Update(20)
+	}
+	v := s.Values()
+	avg := float64(0)
+	for i := 0; i < len(v); i++ {
+		avg += float64(v[i])
+	}
+	avg /= float64(len(v))
+	if avg > 16 || avg < 14 {
+		t.Errorf("out of range [14, 16]: %v\n", avg)
+	}
+}
+
+func TestExpDecaySampleRescale(t *testing.T) {
+	s := NewExpDecaySample(2, 0.001).(*ExpDecaySample)
+	s.update(time.Now(), 1)
+	s.update(time.Now().Add(time.Hour+time.Microsecond), 1)
+	for _, v := range s.values.Values() {
+		if v.k == 0.0 {
+			t.Fatal("v.k == 0.0")
+		}
+	}
+}
+
+// The priority of a value recorded after a long time without updates must be
+// computed after the rescale, or it overflows to +Inf, which the rescale turns
+// into NaN, after which no value is ever accepted again.
+func TestExpDecaySampleLongIdle(t *testing.T) {
+	s := NewExpDecaySample(10, 0.015).(*ExpDecaySample)
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		s.update(now, 1)
+	}
+	later := now.Add(14 * time.Hour)
+	for i := 0; i < 10; i++ {
+		s.update(later, 1000)
+	}
+	for _, v := range s.values.Values() {
+		if math.IsNaN(v.k) || math.IsInf(v.k, 0) {
+			t.Fatalf("v.k: %v\n", v.k)
+		}
+	}
+	if min := s.Min(); 1000 != min {
+		t.Errorf("s.Min(): 1000 != %v\n", min)
+	}
+}
+
+func TestExpDecaySampleSnapshot(t *testing.T) {
+	now := time.Now()
+	rand.Seed(1)
+	s := NewExpDecaySample(100, 0.99).(*ExpDecaySample)
+	for i := 1; i <= 10000; i++ {
+		s.update(now.Add(time.Duration(i)), int64(i))
+	}
+	snapshot := s.Snapshot()
+	s.Update(1)
+	if count := snapshot.Count(); 10000 != count {
+		t.Errorf("snapshot.Count(): 10000 != %v\n", count)
+	}
+	if size := snapshot.Size(); 100 != size {
+		t.Errorf("snapshot.Size(): 100 != %v\n", size)
+	}
+}
+
+func TestExpDecaySampleClear(t *testing.T) {
+	s := NewExpDecaySample(100, 0.99)
+	for i := 0; i < 10; i++ {
+		s.Update(int64(i))
+	}
+	s.Clear()
+	if count := s.Count(); 0 != count {
+		t.Errorf("s.Count(): 0 != %v\n", count)
+	}
+	if size := s.Size(); 0 != size {
+		t.Errorf("s.Size(): 0 != %v\n", size)
+	}
 }
 
 func TestUniformSample(t *testing.T) {
@@ -130,3 +292,271 @@ func TestUniformSampleConcurrentUpdateCount(t *testing.T) {
 	}
 	quit <- struct{}{}
 }
+
+func TestHdrSample(t *testing.T) {
+	s := NewHdrSample(1, 3600000000, 3)
+	for i := 1; i <= 100000; i++ {
+		s.Update(int64(i))
+	}
+	if count := s.Count(); 100000 != count {
+		t.Errorf("s.Count(): 100000 != %v\n", count)
+	}
+	if min := s.Min(); 1 != min {
+		t.Errorf("s.Min(): 1 != %v\n", min)
+	}
+	if max := s.Max(); 100000 != max {
+		t.Errorf("s.Max(): 100000 != %v\n", max)
+	}
+	if sum := s.Sum(); 5000050000 != sum {
+		t.Errorf("s.Sum(): 5000050000 != %v\n", sum)
+	}
+	if mean := s.Mean(); 50000.5 != mean {
+		t.Errorf("s.Mean(): 50000.5 != %v\n", mean)
+	}
+	if stdDev := s.StdDev(); math.Abs(stdDev-28867.513458037913) > 1e-6 {
+		t.Errorf("s.StdDev(): 28867.513458037913 != %v\n", stdDev)
+	}
+	ps := s.Percentiles([]float64{0.5, 0.99, 0.999})
+	for i, expected := range []float64{50000, 99000, 99900} {
+		if math.Abs(ps[i]-expected)/expected > 0.001 {
+			t.Errorf("percentile %d: %v != %v\n", i, expected, ps[i])
+		}
+	}
+}
+
+func TestHdrSampleConstantMemory(t *testing.T) {
+	s := NewHdrSample(1, 1000000, 2).(*HdrSample)
+	size := len(s.counts)
+	for i := 0; i < 1000000; i++ {
+		s.Update(rand.Int63n(2000000) - 1000)
+	}
+	if len(s.counts) != size {
+		t.Errorf("len(s.counts): %v != %v\n", size, len(s.counts))
+	}
+	if count := s.Count(); 1000000 != count {
+		t.Errorf("s.Count(): 1000000 != %v\n", count)
+	}
+	if p := s.Percentile(1.0); float64(s.Max()) != p {
+		t.Errorf("s.Percentile(1.0): %v != %v\n", s.Max(), p)
+	}
+}
+
+func TestHdrSampleSnapshot(t *testing.T) {
+	s := NewHdrSample(1, 1000000, 3)
+	for i := 1; i <= 1000; i++ {
+		s.Update(int64(i))
+	}
+	snapshot := s.Snapshot()
+	s.Update(1000000)
+	if count := snapshot.Count(); 1000 != count {
+		t.Errorf("snapshot.Count(): 1000 != %v\n", count)
+	}
+	if max := snapshot.Max(); 1000 != max {
+		t.Errorf("snapshot.Max(): 1000 != %v\n", max)
+	}
+	if p := snapshot.Percentile(0.5); 500 != p {
+		t.Errorf("snapshot.Percentile(0.5): 500 != %v\n", p)
+	}
+	if size := snapshot.Size(); 1000 != size {
+		t.Errorf("snapshot.Size(): 1000 != %v\n", size)
+	}
+}
+
+func TestHdrSampleMerge(t *testing.T) {
+	a, b, all := NewHdrSample(1, 1000000, 3), NewHdrSample(1, 1000000, 3), NewHdrSample(1, 1000000, 3)
+	for i := 1; i <= 10000; i++ {
+		v := rand.Int63n(100000)
+		if 0 == i%3 {
+			a.Update(v)
+		} else {
+			b.Update(v)
+		}
+		all.Update(v)
+	}
+	merged, err := a.Snapshot().(*HdrSampleSnapshot).Merge(b.Snapshot().(*HdrSampleSnapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Count() != all.Count() || merged.Sum() != all.Sum() || merged.Min() != all.Min() || merged.Max() != all.Max() {
+		t.Errorf("merged: %v != %v\n", merged, all)
+	}
+	if math.Abs(merged.StdDev()-all.StdDev()) > 1e-6 {
+		t.Errorf("merged.StdDev(): %v != %v\n", all.StdDev(), merged.StdDev())
+	}
+	ps := []float64{0.5, 0.75, 0.99, 0.999}
+	expected := all.Percentiles(ps)
+	for i, p := range merged.Percentiles(ps) {
+		if expected[i] != p {
+			t.Errorf("percentile %v: %v != %v\n", ps[i], expected[i], p)
+		}
+	}
+	if _, err := merged.Merge(NewHdrSample(1, 1000000, 2).Snapshot().(*HdrSampleSnapshot)); nil == err {
+		t.Error("merged snapshots of different precisions")
+	}
+}
+
+func TestHdrSampleOutOfRange(t *testing.T) {
+	s := NewHdrSample(10, 1000, 2)
+	s.Update(-5)
+	s.Update(100000)
+	if min, max := s.Min(), s.Max(); -5 != min || 100000 != max {
+		t.Errorf("s.Min(), s.Max(): -5, 100000 != %v, %v\n", min, max)
+	}
+	if ps := s.Percentiles([]float64{0.0, 0.5, 1.0}); -5 != ps[0] || ps[1] > 10 || 100000 != ps[2] {
+		t.Errorf("s.Percentiles(): [-5 <=10 100000] != %v\n", ps)
+	}
+}
+
+func TestHdrSampleSnapshotAndReset(t *testing.T) {
+	h := NewHistogram(NewHdrSample(1, 1000000, 3)).(*StandardHistogram)
+	h.Update(47)
+	snapshot := h.SnapshotAndReset()
+	h.Update(1)
+	if count, max := snapshot.Count(), snapshot.Max(); 1 != count || 47 != max {
+		t.Errorf("snapshot.Count(), snapshot.Max(): 1, 47 != %v, %v\n", count, max)
+	}
+	if count := h.Count(); 1 != count {
+		t.Errorf("h.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestBucketSample(t *testing.T) {
+	s := NewBucketSample(LinearBuckets(10, 10, 10)).(BucketSample)
+	for i := 1; i <= 100; i++ {
+		s.Update(int64(i))
+	}
+	s.Update(1000)
+	if count := s.Count(); 101 != count {
+		t.Errorf("s.Count(): 101 != %v\n", count)
+	}
+	if sum := s.Sum(); 6050 != sum {
+		t.Errorf("s.Sum(): 6050 != %v\n", sum)
+	}
+	if min, max := s.Min(), s.Max(); 1 != min || 1000 != max {
+		t.Errorf("s.Min(), s.Max(): 1, 1000 != %v, %v\n", min, max)
+	}
+	bounds, counts := s.Buckets()
+	if 10 != len(bounds) || 10 != bounds[0] || 100 != bounds[9] {
+		t.Errorf("bounds: %v\n", bounds)
+	}
+	for i, count := range counts {
+		if int64(10*(i+1)) != count {
+			t.Errorf("counts[%d]: %v != %v\n", i, 10*(i+1), count)
+		}
+	}
+	if size := s.Size(); 11 != size {
+		t.Errorf("s.Size(): 11 != %v\n", size)
+	}
+}
+
+func TestBucketSamplePercentile(t *testing.T) {
+	s := NewBucketSample([]float64{10, 20, 40})
+	for i := 1; i <= 40; i++ {
+		s.Update(int64(i))
+	}
+	ps := s.Percentiles([]float64{0.0, 0.25, 0.5, 0.75, 1.0})
+	for i, expected := range []float64{1, 10, 20, 30, 40} {
+		if expected != ps[i] {
+			t.Errorf("ps[%d]: %v != %v\n", i, expected, ps[i])
+		}
+	}
+}
+
+func TestBucketSampleStatistics(t *testing.T) {
+	s := NewBucketSample(ExponentialBuckets(1, 2, 10))
+	for _, v := range []int64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.Update(v)
+	}
+	if mean := s.Mean(); 5 != mean {
+		t.Errorf("s.Mean(): 5 != %v\n", mean)
+	}
+	if stdDev := s.StdDev(); 2 != stdDev {
+		t.Errorf("s.StdDev(): 2 != %v\n", stdDev)
+	}
+}
+
+func TestBucketSampleVarianceLargeValues(t *testing.T) {
+	s := NewBucketSample(ExponentialBuckets(1, 2, 10))
+	for _, v := range []int64{4, 7, 13, 16} {
+		s.Update(1e9 + v)
+	}
+	if variance := s.Variance(); 22.5 != variance {
+		t.Errorf("s.Variance(): 22.5 != %v\n", variance)
+	}
+}
+
+func TestBucketSampleSnapshotAndReset(t *testing.T) {
+	h := NewBucketHistogram([]float64{10}).(*StandardHistogram)
+	h.Update(5)
+	h.Update(50)
+	snapshot := h.SnapshotAndReset()
+	h.Update(1)
+	if count, sum := snapshot.Count(), snapshot.Sum(); 2 != count || 55 != sum {
+		t.Errorf("snapshot.Count(), snapshot.Sum(): 2, 55 != %v, %v\n", count, sum)
+	}
+	if count, min, max := h.Count(), h.Min(), h.Max(); 1 != count || 1 != min || 1 != max {
+		t.Errorf("h.Count(), h.Min(), h.Max(): 1, 1, 1 != %v, %v, %v\n", count, min, max)
+	}
+}
+
+func TestBucketSampleConcurrentUpdate(t *testing.T) {
+	s := NewBucketSample(LinearBuckets(0, 100, 10))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				s.Update(int64(j))
+			}
+		}()
+	}
+	wg.Wait()
+	if count, sum := s.Count(), s.Sum(); 8000 != count || 8*499500 != sum {
+		t.Errorf("s.Count(), s.Sum(): 8000, %v != %v, %v\n", 8*499500, count, sum)
+	}
+}
+
+func TestBucketSampleConcurrentSnapshotAndReset(t *testing.T) {
+	s := NewBucketSample([]float64{10}).(*StandardBucketSample)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for j := 0; j < 10000; j++ {
+			s.Update(5)
+		}
+	}()
+	var count, sum int64
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		snapshot := s.SnapshotAndReset().(*BucketSampleSnapshot)
+		if _, counts := snapshot.Buckets(); snapshot.Count() != counts[0] || 5*snapshot.Count() != snapshot.Sum() {
+			t.Fatalf("inconsistent snapshot: count %v, sum %v, buckets %v\n", snapshot.Count(), snapshot.Sum(), counts)
+		}
+		count += snapshot.Count()
+		sum += snapshot.Sum()
+	}
+	if 10000 != count || 50000 != sum {
+		t.Errorf("count, sum: 10000, 50000 != %v, %v\n", count, sum)
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	bounds := ExponentialBuckets(100, 10, 3)
+	if 3 != len(bounds) || 100 != bounds[0] || 1000 != bounds[1] || 10000 != bounds[2] {
+		t.Errorf("ExponentialBuckets(100, 10, 3): %v\n", bounds)
+	}
+}
+
+func TestNewBucketSampleUnsorted(t *testing.T) {
+	defer func() {
+		if nil == recover() {
+			t.Error("unsorted bounds accepted")
+		}
+	}()
+	NewBucketSample([]float64{2, 1})
+}
@@ -0,0 +1,278 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStatsDMTU is the default maximum size of a StatsD packet.  It keeps
+// packets below the common 1500 byte Ethernet MTU once IP and UDP headers are
+// added.
+const DefaultStatsDMTU = 1432
+
+// StatsDConfig provides a container with configuration parameters for the
+// StatsD reporter.
+type StatsDConfig struct {
+	Addr          string        // UDP address of the StatsD agent
+	Registry      Registry      // Registry to be exported
+	FlushInterval time.Duration // Flush interval
+	Prefix        string        // Prefix to be prepended to metric names
+	MTU           int           // Maximum packet size, DefaultStatsDMTU if zero
+	Percentiles   []float64     // Percentiles to export from histograms and timers
+	DogStatsD     bool          // Whether to append metric tags and MetricVec labels in DogStatsD syntax
+}
+
+// StatsDReporter periodically sends the metrics of a registry to a StatsD
+// agent over UDP.
+//
+// Counters, Meters and the counts of Histograms and Timers are sent as
+// deltas since the previous flush (|c), Gauges and GaugeFloat64s as gauges
+// (|g), and the percentiles of Histograms and Timers as gauges named
+// "<name>.p<percentile>".  Timer percentiles are sent in milliseconds.  The
+// tags of metrics in a TaggedRegistry and the labels of the children of a
+// MetricVec are sent as DogStatsD tags or, without DogStatsD, appended to the
+// name as "<name>.<value1>.<value2>", so that series which share a name are
+// not merged.  The tags of a MultiMetric are only sent with DogStatsD.
+type StatsDReporter struct {
+	config StatsDConfig
+	conn   net.Conn
+	last   map[string]int64 // counts sent on the previous flush
+	seen   map[string]int64 // counts sent on the current flush
+	buf    []byte
+}
+
+// NewStatsDReporter constructs a new StatsDReporter sending to the agent at
+// the configured address.
+func NewStatsDReporter(c StatsDConfig) (*StatsDReporter, error) {
+	if nil == c.Registry {
+		c.Registry = DefaultRegistry
+	}
+	if 0 == c.FlushInterval {
+		c.FlushInterval = 10 * time.Second
+	}
+	if 0 == c.MTU {
+		c.MTU = DefaultStatsDMTU
+	}
+	if nil == c.Percentiles {
+		c.Percentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+	}
+	conn, err := net.Dial("udp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsDReporter{
+		config: c,
+		conn:   conn,
+		last:   make(map[string]int64),
+		buf:    make([]byte, 0, c.MTU),
+	}, nil
+}
+
+// Close closes the connection to the agent.
+func (r *StatsDReporter) Close() error {
+	return r.conn.Close()
+}
+
+// Run flushes the registry on every flush interval until the context is
+// cancelled.
+func (r *StatsDReporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				log.Printf("ERROR metrics: statsd: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush sends the current state of the registry to the agent, batching lines
+// into packets of at most MTU bytes.  The counts of metrics which are no
+// longer in the registry are forgotten.
+func (r *StatsDReporter) Flush() error {
+	r.buf = r.buf[:0]
+	r.seen = make(map[string]int64, len(r.last))
+	err := r.flush()
+	if nil == err {
+		r.last = r.seen
+	} else {
+		// metrics not written yet may still be in the registry
+		for k, v := range r.seen {
+			r.last[k] = v
+		}
+	}
+	r.seen = nil
+	return err
+}
+
+func (r *StatsDReporter) flush() error {
+	for _, e := range SnapshotRegistry(r.config.Registry).entries {
+		name, tags := r.config.Prefix+e.name, e.tags
+		if !r.config.DogStatsD {
+			name, tags = statsDPath(name, tags), nil
+		}
+		if err := r.write(name, tags, e.metric); err != nil {
+			return err
+		}
+	}
+	return r.send()
+}
+
+func (r *StatsDReporter) write(name string, tags map[string]string, m Metric) error {
+	switch metric := m.(type) {
+	case Counter:
+		return r.delta(name, tags, metric.Count())
+	case WatermarkGauge:
+		if err := r.line(name, strconv.FormatInt(metric.Value(), 10), "g", tags); err != nil {
+			return err
+		}
+		if err := r.line(name+".max", strconv.FormatInt(metric.Max(), 10), "g", tags); err != nil {
+			return err
+		}
+		return r.line(name+".min", strconv.FormatInt(metric.Min(), 10), "g", tags)
+	case Gauge:
+		return r.line(name, strconv.FormatInt(metric.Value(), 10), "g", tags)
+	case GaugeFloat64:
+		return r.line(name, formatStatsDFloat(metric.Value()), "g", tags)
+	case Histogram:
+		h := metric.Snapshot()
+		if err := r.delta(name+".count", tags, h.Count()); err != nil {
+			return err
+		}
+		return r.percentiles(name, tags, h.Percentiles(r.config.Percentiles), 1)
+	case Meter:
+		return r.delta(name, tags, metric.Count())
+	case Timer:
+		t := metric.Snapshot()
+		if err := r.delta(name+".count", tags, t.Count()); err != nil {
+			return err
+		}
+		return r.percentiles(name, tags, t.Percentiles(r.config.Percentiles), float64(time.Millisecond))
+	case MultiMetric:
+		mm := metric.Snapshot()
+		members := mm.Metrics()
+		mmTags := mergeTags(tags, mm.Tags())
+		keys := make([]string, 0, len(members))
+		for k := range members {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := r.write(name+"."+k, mmTags, members[k]); err != nil {
+				return err
+			}
+		}
+	case MetricVec:
+		var err error
+		labelNames := metric.LabelNames()
+		metric.Each(func(labels map[string]string, m Metric) {
+			if nil != err {
+				return
+			}
+			if r.config.DogStatsD {
+				err = r.write(name, mergeTags(tags, labels), m)
+				return
+			}
+			path := name
+			for _, l := range labelNames {
+				path += "." + labels[l]
+			}
+			err = r.write(path, tags, m)
+		})
+		return err
+	}
+	return nil
+}
+
+// delta writes the difference between the given count and the count sent on
+// the previous flush as a StatsD counter.
+func (r *StatsDReporter) delta(name string, tags map[string]string, count int64) error {
+	key := formatTaggedName(name, tags)
+	delta := count - r.last[key]
+	r.seen[key] = count
+	return r.line(name, strconv.FormatInt(delta, 10), "c", tags)
+}
+
+func (r *StatsDReporter) percentiles(name string, tags map[string]string, ps []float64, scale float64) error {
+	for i, p := range r.config.Percentiles {
+		if err := r.line(name+".p"+formatPercentile(p), formatStatsDFloat(ps[i]/scale), "g", tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// line appends a single StatsD line to the current packet, sending the packet
+// first if the line would not fit.
+func (r *StatsDReporter) line(name, value, typ string, tags map[string]string) error {
+	l := sanitizeStatsD(name) + ":" + value + "|" + typ + r.tagSuffix(tags)
+	if len(r.buf) > 0 && len(r.buf)+1+len(l) > r.config.MTU {
+		if err := r.send(); err != nil {
+			return err
+		}
+	}
+	if len(r.buf) > 0 {
+		r.buf = append(r.buf, '\n')
+	}
+	r.buf = append(r.buf, l...)
+	return nil
+}
+
+// tagSuffix returns the DogStatsD tag suffix for the given tags, or an empty
+// string if DogStatsD tags are disabled or there are no tags.
+func (r *StatsDReporter) tagSuffix(tags map[string]string) string {
+	if !r.config.DogStatsD || 0 == len(tags) {
+		return ""
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, statsDTagEscaper.Replace(k)+":"+statsDTagEscaper.Replace(v))
+	}
+	sort.Strings(pairs)
+	return "|#" + strings.Join(pairs, ",")
+}
+
+func (r *StatsDReporter) send() error {
+	if 0 == len(r.buf) {
+		return nil
+	}
+	_, err := r.conn.Write(r.buf)
+	r.buf = r.buf[:0]
+	return err
+}
+
+var (
+	statsDNameEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_")
+	statsDTagEscaper  = strings.NewReplacer(":", "_", "|", "_", ",", "_", "#", "_", "\n", "_")
+)
+
+// statsDPath appends the given tag values to a name in the order of their
+// keys.
+func statsDPath(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name += "." + tags[k]
+	}
+	return name
+}
+
+func sanitizeStatsD(name string) string {
+	return statsDNameEscaper.Replace(name)
+}
+
+func formatStatsDFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
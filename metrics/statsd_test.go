@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStatsDListener(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readStatsDPackets(t *testing.T, conn net.PacketConn, n int) []string {
+	t.Helper()
+	packets := make([]string, 0, n)
+	buf := make([]byte, 65536)
+	for i := 0; i < n; i++ {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		l, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, string(buf[:l]))
+	}
+	return packets
+}
+
+func TestStatsDReporter(t *testing.T) {
+	conn := newStatsDListener(t)
+	defer conn.Close()
+
+	r := NewRegistry()
+	c := NewRegisteredCounter("counter", r)
+	c.Inc(5)
+	NewRegisteredGauge("gauge", r).Update(47)
+	NewRegisteredGaugeFloat64("gauge.float", r).Update(0.5)
+	h := NewRegisteredHistogram("histogram", r, NewUniformSample(100))
+	h.Update(10)
+	mm := NewRegisteredMultiMetric("http", map[string]string{"method": "GET", "code": "200"}, r)
+	mm.GetOrAdd("hits", NewCounter()).(Counter).Inc(2)
+
+	reporter, err := NewStatsDReporter(StatsDConfig{
+		Addr:        conn.LocalAddr().String(),
+		Registry:    r,
+		Prefix:      "app.",
+		Percentiles: []float64{0.5, 0.999},
+		DogStatsD:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Close()
+
+	if err := reporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"app.counter:5|c",
+		"app.gauge:47|g",
+		"app.gauge.float:0.5|g",
+		"app.histogram.count:1|c",
+		"app.histogram.p50:10|g",
+		"app.histogram.p99_9:10|g",
+		"app.http.hits:2|c|#code:200,method:GET",
+	}, "\n")
+	if packets := readStatsDPackets(t, conn, 1); expected != packets[0] {
+		t.Errorf("packet:\n%s\n!=\n%s", expected, packets[0])
+	}
+
+	// counters are sent as deltas
+	c.Inc(3)
+	if err := reporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if packets := readStatsDPackets(t, conn, 1); !strings.HasPrefix(packets[0], "app.counter:3|c\n") {
+		t.Errorf("packet: %q\n", packets[0])
+	}
+}
+
+func TestStatsDReporterMTU(t *testing.T) {
+	conn := newStatsDListener(t)
+	defer conn.Close()
+
+	r := NewRegistry()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		NewRegisteredGauge(name, r).Update(1)
+	}
+
+	reporter, err := NewStatsDReporter(StatsDConfig{
+		Addr:     conn.LocalAddr().String(),
+		Registry: r,
+		MTU:      11,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Close()
+
+	if err := reporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	packets := readStatsDPackets(t, conn, 2)
+	if "a:1|g\nb:1|g" != packets[0] {
+		t.Errorf("packets[0]: %q\n", packets[0])
+	}
+	if "c:1|g\nd:1|g" != packets[1] {
+		t.Errorf("packets[1]: %q\n", packets[1])
+	}
+}
+
+func TestStatsDReporterRun(t *testing.T) {
+	conn := newStatsDListener(t)
+	defer conn.Close()
+
+	r := NewRegistry()
+	NewRegisteredGauge("foo", r).Update(1)
+
+	reporter, err := NewStatsDReporter(StatsDConfig{
+		Addr:          conn.LocalAddr().String(),
+		Registry:      r,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reporter.Run(ctx)
+		close(done)
+	}()
+	if packets := readStatsDPackets(t, conn, 1); "foo:1|g" != packets[0] {
+		t.Errorf("packet: %q\n", packets[0])
+	}
+	cancel()
+	<-done
+}
+
+func TestStatsDReporterForgetsUnregistered(t *testing.T) {
+	conn := newStatsDListener(t)
+	defer conn.Close()
+
+	r := NewRegistry()
+	NewRegisteredCounter("kept", r).Inc(1)
+	NewRegisteredCounter("gone", r).Inc(1)
+
+	reporter, err := NewStatsDReporter(StatsDConfig{
+		Addr:     conn.LocalAddr().String(),
+		Registry: r,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Close()
+
+	if err := reporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	readStatsDPackets(t, conn, 1)
+	r.Unregister("gone")
+	if err := reporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	readStatsDPackets(t, conn, 1)
+	if _, ok := reporter.last["gone"]; ok || 1 != len(reporter.last) {
+		t.Errorf("reporter.last: %v\n", reporter.last)
+	}
+}
+
+func TestStatsDReporterTagged(t *testing.T) {
+	conn := newStatsDListener(t)
+	defer conn.Close()
+
+	r := NewRegistry().(TaggedRegistry)
+	r.RegisterTagged("hits", map[string]string{"host": "a"}, NewCounter())
+	r.RegisterTagged("hits", map[string]string{"host": "b"}, NewCounter())
+	mm := NewMultiMetric(map[string]string{"method": "GET"})
+	mm.GetOrAdd("hits", NewCounter()).(Counter).Inc(1)
+	r.RegisterTagged("http", map[string]string{"host": "a"}, mm)
+
+	for _, c := range []struct {
+		dogStatsD bool
+		expected  string
+	}{
+		{true, "hits:0|c|#host:a\nhits:0|c|#host:b\nhttp.hits:1|c|#host:a,method:GET"},
+		{false, "hits.a:0|c\nhits.b:0|c\nhttp.a.hits:1|c"},
+	} {
+		reporter, err := NewStatsDReporter(StatsDConfig{
+			Addr:      conn.LocalAddr().String(),
+			Registry:  r,
+			DogStatsD: c.dogStatsD,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := reporter.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if packets := readStatsDPackets(t, conn, 1); c.expected != packets[0] {
+			t.Errorf("packet:\n%s\n!=\n%s", c.expected, packets[0])
+		}
+		reporter.Close()
+	}
+}
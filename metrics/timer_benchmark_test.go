@@ -0,0 +1,12 @@
+package metrics
+
+import "testing"
+
+func BenchmarkTimer(b *testing.B) {
+	tm := NewTimer()
+	defer tm.Stop()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tm.Update(1)
+	}
+}
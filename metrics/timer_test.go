@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+// Check the interfaces are satisfied
+func TestTimer_impl(t *testing.T) {
+	var _ Timer = new(NilTimer)
+	var _ Timer = new(TimerSnapshot)
+	var _ Timer = new(StandardTimer)
+}
+
+func TestGetOrRegisterTimer(t *testing.T) {
+	r := NewRegistry()
+	tm := NewRegisteredTimer("foo", r)
+	defer tm.Stop()
+	tm.Update(47)
+	if tm := GetOrRegisterTimer("foo", r); 1 != tm.Count() {
+		t.Fatal(tm)
+	}
+}
+
+func TestTimerDurationPercentiles(t *testing.T) {
+	tm := NewTimer()
+	defer tm.Stop()
+	for i := 1; i <= 100; i++ {
+		tm.Update(time.Duration(i) * time.Millisecond)
+	}
+	if p := tm.DurationPercentile(0.5); 50500*time.Microsecond != p {
+		t.Errorf("tm.DurationPercentile(0.5): 50.5ms != %v\n", p)
+	}
+	ps := tm.DurationPercentiles([]float64{0.5, 0.99})
+	if 50500*time.Microsecond != ps[0] {
+		t.Errorf("median: 50.5ms != %v\n", ps[0])
+	}
+	if 99990*time.Microsecond != ps[1] {
+		t.Errorf("99th percentile: 99.99ms != %v\n", ps[1])
+	}
+}
+
+func TestTimerExtremes(t *testing.T) {
+	tm := NewTimer()
+	defer tm.Stop()
+	tm.Update(time.Duration(9223372036854775807))
+	tm.Update(0)
+	if stdDev := tm.StdDev(); 4.611686018427388e+18 != stdDev {
+		t.Errorf("tm.StdDev(): 4.611686018427388e+18 != %v\n", stdDev)
+	}
+}
+
+func TestTimerCustom(t *testing.T) {
+	tm := NewCustomTimer(NilHistogram{}, NilMeter{})
+	tm.Update(time.Second)
+	if count := tm.Snapshot().Count(); 0 != count {
+		t.Errorf("tm.Snapshot().Count(): 0 != %v\n", count)
+	}
+	tm = NewCustomTimer(NewHistogram(NewExpDecaySample(1028, 0.015)), NewMeter())
+	defer tm.Stop()
+	tm.Update(time.Second)
+	if count := tm.Snapshot().Count(); 1 != count {
+		t.Errorf("tm.Snapshot().Count(): 1 != %v\n", count)
+	}
+}
+
+func TestTimerFunc(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	timerNow = func() time.Time { return now }
+	defer func() { timerNow = time.Now }()
+	tm := NewTimer()
+	defer tm.Stop()
+	tm.Time(func() { now = now.Add(50 * time.Millisecond) })
+	if max := tm.Max(); 50e6 != max {
+		t.Errorf("tm.Max(): 50e6 != %v\n", max)
+	}
+}
+
+func TestTimerSnapshot(t *testing.T) {
+	tm := NewTimer()
+	defer tm.Stop()
+	tm.Update(time.Second)
+	snapshot := tm.Snapshot()
+	tm.Update(2 * time.Second)
+	if count := snapshot.Count(); 1 != count {
+		t.Errorf("snapshot.Count(): 1 != %v\n", count)
+	}
+	if p := snapshot.DurationPercentile(0.5); time.Second != p {
+		t.Errorf("snapshot.DurationPercentile(0.5): 1s != %v\n", p)
+	}
+}
+
+func TestTimerUpdateSince(t *testing.T) {
+	tm := NewTimer()
+	defer tm.Stop()
+	tm.UpdateSince(time.Now().Add(-time.Second))
+	if min := tm.Min(); int64(time.Second) > min {
+		t.Errorf("tm.Min(): 1s > %v\n", time.Duration(min))
+	}
+}
+
+func TestTimerZero(t *testing.T) {
+	tm := NewTimer()
+	defer tm.Stop()
+	if count := tm.Count(); 0 != count {
+		t.Errorf("tm.Count(): 0 != %v\n", count)
+	}
+	if min := tm.Min(); 0 != min {
+		t.Errorf("tm.Min(): 0 != %v\n", min)
+	}
+	if max := tm.Max(); 0 != max {
+		t.Errorf("tm.Max(): 0 != %v\n", max)
+	}
+	if rateMean := tm.RateMean(); 0.0 != rateMean {
+		t.Errorf("tm.RateMean(): 0.0 != %v\n", rateMean)
+	}
+}
@@ -0,0 +1,322 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricVec is a Metric that partitions a metric by the values of a fixed set
+// of labels.  Children are created lazily on first use.
+type MetricVec interface {
+	Metric
+
+	Each(func(map[string]string, Metric))
+	LabelNames() []string
+}
+
+// metricVec is the common implementation of the labeled metric vectors.
+type metricVec struct {
+	labelNames []string
+	newMetric  func() Metric
+	children   map[string]*vecChild
+	mutex      sync.RWMutex
+}
+
+type vecChild struct {
+	values []string
+	metric Metric
+}
+
+func newMetricVec(labelNames []string, newMetric func() Metric) *metricVec {
+	names := make([]string, len(labelNames))
+	copy(names, labelNames)
+	return &metricVec{
+		labelNames: names,
+		newMetric:  newMetric,
+		children:   make(map[string]*vecChild),
+	}
+}
+
+// Each calls the given function for each child with its labels.  Children are
+// visited in lexicographical order of their label values.
+func (v *metricVec) Each(fn func(map[string]string, Metric)) {
+	v.mutex.RLock()
+	children := make([]*vecChild, 0, len(v.children))
+	for _, c := range v.children {
+		children = append(children, c)
+	}
+	v.mutex.RUnlock()
+	sort.Slice(children, func(i, j int) bool {
+		a, b := children[i].values, children[j].values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	for _, c := range children {
+		labels := make(map[string]string, len(v.labelNames))
+		for i, name := range v.labelNames {
+			labels[name] = c.values[i]
+		}
+		fn(labels, c.metric)
+	}
+}
+
+// LabelNames returns the label names of the vector.
+func (v *metricVec) LabelNames() []string {
+	names := make([]string, len(v.labelNames))
+	copy(names, v.labelNames)
+	return names
+}
+
+// withLabelValues returns the child for the given label values, creating it
+// if needed.  It panics if the number of values does not match the number of
+// label names.
+func (v *metricVec) withLabelValues(values []string) Metric {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: expected %d label values %v, got %d", len(v.labelNames), v.labelNames, len(values)))
+	}
+	key := vecKey(values)
+	v.mutex.RLock()
+	c, ok := v.children[key]
+	v.mutex.RUnlock()
+	if ok {
+		return c.metric
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if c, ok := v.children[key]; ok {
+		return c.metric
+	}
+	c = &vecChild{values: make([]string, len(values)), metric: v.newMetric()}
+	copy(c.values, values)
+	v.children[key] = c
+	return c.metric
+}
+
+// vecKey returns the key of the child with the given label values.  Every
+// value is prefixed with its length, so that different values never share a
+// key, whatever bytes they contain.
+func vecKey(values []string) string {
+	var b strings.Builder
+	for _, value := range values {
+		b.WriteString(strconv.Itoa(len(value)))
+		b.WriteByte(':')
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// with returns the child for the given labels, creating it if needed.  It
+// panics if the labels do not match the label names.
+func (v *metricVec) with(labels map[string]string) Metric {
+	if len(labels) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: expected labels %v, got %v", v.labelNames, labels))
+	}
+	values := make([]string, len(v.labelNames))
+	for i, name := range v.labelNames {
+		value, ok := labels[name]
+		if !ok {
+			panic(fmt.Sprintf("metrics: expected labels %v, got %v", v.labelNames, labels))
+		}
+		values[i] = value
+	}
+	return v.withLabelValues(values)
+}
+
+// SnapshotMetric returns a MetricVecSnapshot with a read-only copy of every
+// child of the vector.
+func (v *metricVec) SnapshotMetric() Metric {
+	return v.snapshotWith(SnapshotMetric)
+}
+
+// snapshotWith returns a MetricVecSnapshot with every child copied by the
+// given function.
+func (v *metricVec) snapshotWith(snapshot func(Metric) Metric) Metric {
+	children := make([]vecSnapshotChild, 0)
+	v.Each(func(labels map[string]string, m Metric) {
+		c := vecSnapshotChild{labels: labels, metric: snapshot(m)}
+		if cm, ok := m.(createdMetric); ok {
+			c.created = cm.Created()
+		}
+		children = append(children, c)
+	})
+	return &MetricVecSnapshot{labelNames: v.LabelNames(), children: children}
+}
+
+// MetricVecSnapshot is a read-only copy of another MetricVec.
+type MetricVecSnapshot struct {
+	labelNames []string
+	children   []vecSnapshotChild
+}
+
+type vecSnapshotChild struct {
+	labels  map[string]string
+	metric  Metric
+	created time.Time
+}
+
+// Each calls the given function for each child at the time the snapshot was
+// taken with its labels, in the order of the vector.
+func (v *MetricVecSnapshot) Each(fn func(map[string]string, Metric)) {
+	for _, c := range v.children {
+		fn(mergeTags(c.labels, nil), c.metric)
+	}
+}
+
+// eachCreated is like Each and also passes the time every child was created,
+// which its snapshot no longer knows.
+func (v *MetricVecSnapshot) eachCreated(fn func(map[string]string, Metric, time.Time)) {
+	for _, c := range v.children {
+		fn(mergeTags(c.labels, nil), c.metric, c.created)
+	}
+}
+
+// LabelNames returns the label names of the vector.
+func (v *MetricVecSnapshot) LabelNames() []string {
+	names := make([]string, len(v.labelNames))
+	copy(names, v.labelNames)
+	return names
+}
+
+// SnapshotMetric returns the snapshot.
+func (v *MetricVecSnapshot) SnapshotMetric() Metric { return v }
+
+// mergeTags returns a new map with the tags of both maps, b taking precedence.
+func mergeTags(a, b map[string]string) map[string]string {
+	merged := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// CounterVec is a MetricVec of Counters.
+type CounterVec struct {
+	*metricVec
+}
+
+// GetOrRegisterCounterVec returns an existing CounterVec or constructs and
+// registers a new one.
+func GetOrRegisterCounterVec(name string, r Registry, labelNames ...string) *CounterVec {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return mustGetOrRegisterAs(r, name, func() *CounterVec { return NewCounterVec(labelNames...) })
+}
+
+// NewCounterVec constructs a new CounterVec with the given label names.
+func NewCounterVec(labelNames ...string) *CounterVec {
+	return &CounterVec{newMetricVec(labelNames, func() Metric { return NewCounter() })}
+}
+
+// NewRegisteredCounterVec constructs and registers a new CounterVec.
+func NewRegisteredCounterVec(name string, r Registry, labelNames ...string) *CounterVec {
+	c := NewCounterVec(labelNames...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// With returns the Counter for the given labels.
+func (v *CounterVec) With(labels map[string]string) Counter {
+	return v.with(labels).(Counter)
+}
+
+// WithLabelValues returns the Counter for the given label values, in the
+// order of the label names.
+func (v *CounterVec) WithLabelValues(values ...string) Counter {
+	return v.withLabelValues(values).(Counter)
+}
+
+// GaugeVec is a MetricVec of Gauges.
+type GaugeVec struct {
+	*metricVec
+}
+
+// GetOrRegisterGaugeVec returns an existing GaugeVec or constructs and
+// registers a new one.
+func GetOrRegisterGaugeVec(name string, r Registry, labelNames ...string) *GaugeVec {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return mustGetOrRegisterAs(r, name, func() *GaugeVec { return NewGaugeVec(labelNames...) })
+}
+
+// NewGaugeVec constructs a new GaugeVec with the given label names.
+func NewGaugeVec(labelNames ...string) *GaugeVec {
+	return &GaugeVec{newMetricVec(labelNames, func() Metric { return NewGauge() })}
+}
+
+// NewRegisteredGaugeVec constructs and registers a new GaugeVec.
+func NewRegisteredGaugeVec(name string, r Registry, labelNames ...string) *GaugeVec {
+	c := NewGaugeVec(labelNames...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// With returns the Gauge for the given labels.
+func (v *GaugeVec) With(labels map[string]string) Gauge {
+	return v.with(labels).(Gauge)
+}
+
+// WithLabelValues returns the Gauge for the given label values, in the order
+// of the label names.
+func (v *GaugeVec) WithLabelValues(values ...string) Gauge {
+	return v.withLabelValues(values).(Gauge)
+}
+
+// HistogramVec is a MetricVec of Histograms.  Every child gets its own Sample
+// from the function given to NewHistogramVec.
+type HistogramVec struct {
+	*metricVec
+}
+
+// GetOrRegisterHistogramVec returns an existing HistogramVec or constructs and
+// registers a new one.
+func GetOrRegisterHistogramVec(name string, r Registry, newSample func() Sample, labelNames ...string) *HistogramVec {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return mustGetOrRegisterAs(r, name, func() *HistogramVec { return NewHistogramVec(newSample, labelNames...) })
+}
+
+// NewHistogramVec constructs a new HistogramVec with the given label names.
+func NewHistogramVec(newSample func() Sample, labelNames ...string) *HistogramVec {
+	return &HistogramVec{newMetricVec(labelNames, func() Metric { return NewHistogram(newSample()) })}
+}
+
+// NewRegisteredHistogramVec constructs and registers a new HistogramVec.
+func NewRegisteredHistogramVec(name string, r Registry, newSample func() Sample, labelNames ...string) *HistogramVec {
+	c := NewHistogramVec(newSample, labelNames...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// With returns the Histogram for the given labels.
+func (v *HistogramVec) With(labels map[string]string) Histogram {
+	return v.with(labels).(Histogram)
+}
+
+// WithLabelValues returns the Histogram for the given label values, in the
+// order of the label names.
+func (v *HistogramVec) WithLabelValues(values ...string) Histogram {
+	return v.withLabelValues(values).(Histogram)
+}
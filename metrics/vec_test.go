@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+// Check the interfaces are satisfied
+func TestMetricVec_impl(t *testing.T) {
+	var _ MetricVec = new(CounterVec)
+	var _ MetricVec = new(GaugeVec)
+	var _ MetricVec = new(HistogramVec)
+	var _ MetricVec = new(MetricVecSnapshot)
+	var _ Snapshotter = new(CounterVec)
+}
+
+func TestCounterVec(t *testing.T) {
+	v := NewCounterVec("method", "code")
+	v.WithLabelValues("GET", "200").Inc(1)
+	v.With(map[string]string{"code": "200", "method": "GET"}).Inc(1)
+	v.WithLabelValues("POST", "500").Inc(3)
+
+	if count := v.WithLabelValues("GET", "200").Count(); 2 != count {
+		t.Errorf("GET 200: 2 != %v\n", count)
+	}
+	i := 0
+	v.Each(func(labels map[string]string, m Metric) {
+		i++
+		if 1 == i && ("GET" != labels["method"] || "200" != labels["code"] || 2 != m.(Counter).Count()) {
+			t.Errorf("first child: %v %v\n", labels, m)
+		}
+		if 2 == i && ("POST" != labels["method"] || 3 != m.(Counter).Count()) {
+			t.Errorf("second child: %v %v\n", labels, m)
+		}
+	})
+	if 2 != i {
+		t.Errorf("children: 2 != %d\n", i)
+	}
+}
+
+func TestCounterVecSeparatorInValues(t *testing.T) {
+	v := NewCounterVec("a", "b")
+	v.WithLabelValues("a\xffb", "c").Inc(1)
+	v.WithLabelValues("a", "b\xffc").Inc(2)
+	if count := v.WithLabelValues("a\xffb", "c").Count(); 1 != count {
+		t.Errorf("a\\xffb c: 1 != %v\n", count)
+	}
+	i := 0
+	v.Each(func(labels map[string]string, m Metric) {
+		i++
+		if 1 == i && ("a" != labels["a"] || 2 != m.(Counter).Count()) {
+			t.Errorf("first child: %v %v\n", labels, m)
+		}
+	})
+	if 2 != i {
+		t.Errorf("children: 2 != %d\n", i)
+	}
+}
+
+func TestCounterVecConcurrent(t *testing.T) {
+	v := NewCounterVec("worker")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				v.WithLabelValues("shared").Inc(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if count := v.WithLabelValues("shared").Count(); 800 != count {
+		t.Errorf("count: 800 != %v\n", count)
+	}
+}
+
+func TestMetricVecLabelMismatch(t *testing.T) {
+	v := NewGaugeVec("a", "b")
+	for _, fn := range []func(){
+		func() { v.WithLabelValues("x") },
+		func() { v.With(map[string]string{"a": "x", "c": "y"}) },
+	} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Error("expected panic")
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestGetOrRegisterHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	newSample := func() Sample { return NewUniformSample(100) }
+	NewRegisteredHistogramVec("foo", r, newSample, "path").WithLabelValues("/").Update(47)
+	v := GetOrRegisterHistogramVec("foo", r, newSample, "path")
+	if h := v.WithLabelValues("/"); 1 != h.Count() {
+		t.Fatal(h)
+	}
+	if h := v.WithLabelValues("/other"); 0 != h.Count() {
+		t.Fatal(h)
+	}
+}
+
+func TestWritePrometheusVec(t *testing.T) {
+	r := NewRegistry()
+	v := NewRegisteredCounterVec("requests", r, "method")
+	v.WithLabelValues("POST").Inc(2)
+	v.WithLabelValues("GET").Inc(1)
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, r); nil != err {
+		t.Fatal(err)
+	}
+	expected := `# TYPE requests counter
+requests{method="GET"} 1
+requests{method="POST"} 2
+`
+	if s := buf.String(); expected != s {
+		t.Errorf("WritePrometheus():\n%s\n!=\n%s", expected, s)
+	}
+}